@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli"
+	"github.com/vroy/git-gb/gb"
+)
+
+func archive(ctx *cli.Context) error {
+	if ctx.Bool("list") {
+		return listArchived(ctx)
+	}
+
+	repo := NewRepo()
+
+	if len(ctx.Args()) == 0 {
+		exit("Usage: git gb archive <branch|pattern>..., or git gb archive --list [base]")
+	}
+
+	protection, err := gb.NewProtection(repo, gb.BaseBranch(repo, ""))
+	check(err)
+
+	failed := false
+
+	for _, pattern := range ctx.Args() {
+		branches, err := gb.MatchBranches(repo, pattern)
+		if err != nil {
+			fmt.Printf("%s%s\n", Red, err)
+			failed = true
+			continue
+		}
+
+		for _, branch := range branches {
+			name, err := branch.Name()
+			check(err)
+
+			if protection.Protected(name) {
+				fmt.Printf("%sskipped %s: protected\n", Yellow, name)
+				continue
+			}
+
+			ref, err := gb.ArchiveBranch(repo, branch, ctx.Bool("tag"))
+			if err != nil {
+				fmt.Printf("%sfailed to archive %s: %s\n", Red, name, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("%sarchived %s to %s\n", Green, name, ref)
+		}
+	}
+
+	fmt.Print(Reset)
+	if failed {
+		os.Exit(ExitError)
+	}
+	return nil
+}
+
+func listArchived(ctx *cli.Context) error {
+	repo := NewRepo()
+
+	baseBranch := gb.BaseBranch(repo, ctx.Args().First())
+	statuses, err := gb.ArchivedStatuses(repo, baseBranch)
+	check(err)
+
+	if len(statuses) == 0 {
+		fmt.Println("No archived branches.")
+		return nil
+	}
+
+	branch_length := MaxBranchLength(statuses)
+
+	for _, status := range statuses {
+		description := ""
+		if status.Description != "" {
+			description = " | " + status.Description
+		}
+
+		fmt.Printf(
+			"%s%s%s | %-*s | behind: %4d | ahead: %4d%s\n",
+			Reset,
+			ColorCode(status),
+			FormattedWhen(status),
+			branch_length, // http://stackoverflow.com/a/28870241
+			status.Name,
+			status.Behind,
+			status.Ahead,
+			description)
+	}
+
+	return nil
+}
+
+func unarchive(ctx *cli.Context) error {
+	repo := NewRepo()
+
+	if len(ctx.Args()) == 0 {
+		exit("Usage: git gb unarchive <branch>...")
+	}
+
+	for _, name := range ctx.Args() {
+		branch, err := gb.Unarchive(repo, name)
+		check(err)
+
+		fmt.Printf("Restored %s at %s\n", name, branch.Target().String()[:7])
+	}
+
+	return nil
+}
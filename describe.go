@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	git "github.com/libgit2/git2go/v34"
+	"github.com/urfave/cli"
+	"github.com/vroy/git-gb/gb"
+)
+
+const descriptionTemplate = `%s
+# Please edit the description for the branch
+#   %s
+# Lines starting with '#' will be stripped.
+`
+
+func editor(repo *git.Repository) string {
+	if e := os.Getenv("GIT_EDITOR"); e != "" {
+		return e
+	}
+
+	if config, err := repo.Config(); err == nil {
+		if e, err := config.LookupString("core.editor"); err == nil && e != "" {
+			return e
+		}
+	}
+
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if e := os.Getenv(name); e != "" {
+			return e
+		}
+	}
+
+	return "vi"
+}
+
+func editDescription(repo *git.Repository, branchName, current string) string {
+	file, err := ioutil.TempFile("", "gb-description-")
+	if err != nil {
+		exit("Could not create temporary file: %s", err)
+	}
+	defer os.Remove(file.Name())
+
+	fmt.Fprintf(file, descriptionTemplate, current, branchName)
+	file.Close()
+
+	// Let the shell split the editor command so values like `code --wait` work.
+	cmd := exec.Command("sh", "-c", editor(repo)+` "$@"`, "editor", file.Name())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		exit("Editor exited with an error: %s", err)
+	}
+
+	edited, err := os.Open(file.Name())
+	if err != nil {
+		exit("Could not read edited description: %s", err)
+	}
+	defer edited.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(edited)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "#") {
+			continue
+		}
+		lines = append(lines, scanner.Text())
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func describe(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		exit("Usage: gb describe <branch> [-m text | --edit]")
+	}
+
+	repo := NewRepo()
+	branchName := ctx.Args().First()
+
+	if _, err := repo.LookupBranch(branchName, git.BranchLocal); err != nil {
+		exit("Error looking up branch '%s'", branchName)
+	}
+
+	current := gb.BranchDescription(repo, branchName)
+
+	var description string
+	switch {
+	case ctx.IsSet("message"):
+		description = strings.TrimSpace(ctx.String("message"))
+	case ctx.Bool("edit"):
+		description = editDescription(repo, branchName, current)
+	default:
+		if current != "" {
+			fmt.Println(current)
+		}
+		return nil
+	}
+
+	if err := gb.SetBranchDescription(repo, branchName, description); err != nil {
+		exit("Could not set description for '%s': %s", branchName, err)
+	}
+
+	return nil
+}
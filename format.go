@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+
+	"github.com/vroy/git-gb/gb"
+)
+
+// writeFormatted writes the statuses without colors, as json, csv or tsv.
+func writeFormatted(format string, columns []string, statuses []gb.BranchStatus) error {
+	switch format {
+	case "json":
+		return gb.WriteJSON(os.Stdout, columns, statuses)
+	case "csv":
+		return writeDelimited(',', columns, statuses)
+	case "tsv":
+		return writeDelimited('\t', columns, statuses)
+	}
+	return nil
+}
+
+func writeDelimited(comma rune, columns []string, statuses []gb.BranchStatus) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Comma = comma
+
+	if err := w.Write(columns); err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = status.Text(column)
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
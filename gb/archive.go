@@ -0,0 +1,285 @@
+package gb
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// Archived branches are kept as refs under ArchivePrefix, or as annotated
+// tags under ArchiveTagPrefix. Neither is a branch, so they are not listed,
+// but their commits stay reachable and survive `git gc`.
+const (
+	ArchivePrefix    = "refs/archive/"
+	ArchiveTagPrefix = "refs/tags/archive/"
+)
+
+// ArchiveDescriptionKey is where the description of a branch archived under
+// ArchivePrefix is kept, since deleting the branch drops its config.
+func ArchiveDescriptionKey(branchName string) string {
+	return fmt.Sprintf("gb.archive.%s.description", branchName)
+}
+
+// ArchiveBranch moves the local branch to ArchivePrefix, or to an annotated
+// tag under ArchiveTagPrefix when tag is set, then deletes it. The tag message
+// keeps the date of the last commit and the branch description; a ref archive
+// keeps the description in ArchiveDescriptionKey. It returns the archive ref
+// name.
+func ArchiveBranch(repo *git.Repository, branch *git.Branch, tag bool) (string, error) {
+	name, err := branch.Name()
+	if err != nil {
+		return "", err
+	}
+
+	if branch.Target() == nil {
+		return "", fmt.Errorf("'%s' is not a direct reference", name)
+	}
+
+	if existing := archiveRef(repo, name); existing != nil {
+		return "", fmt.Errorf("'%s' is already archived as %s", name, existing.Name())
+	}
+
+	refName := ArchivePrefix + name
+
+	if tag {
+		refName = ArchiveTagPrefix + name
+
+		commit, err := repo.LookupCommit(branch.Target())
+		if err != nil {
+			return "", fmt.Errorf("could not lookup commit of '%s': %w", name, err)
+		}
+
+		tagger, err := repo.DefaultSignature()
+		if err != nil {
+			return "", fmt.Errorf("could not tell who archives '%s': %w", name, err)
+		}
+
+		message := fmt.Sprintf("Archive of branch %s, last commit on %s\n",
+			name, commit.Committer().When.Format("2006-01-02 15:04:05 -0700"))
+		if description := BranchDescription(repo, name); description != "" {
+			message += "\n" + description + "\n"
+		}
+
+		if _, err := repo.Tags.Create(strings.TrimPrefix(refName, "refs/tags/"), commit, tagger, message); err != nil {
+			return "", fmt.Errorf("could not archive '%s': %w", name, err)
+		}
+	} else {
+		if err := setArchiveDescription(repo, name, BranchDescription(repo, name)); err != nil {
+			return "", fmt.Errorf("could not keep the description of '%s': %w", name, err)
+		}
+
+		msg := fmt.Sprintf("gb: archive %s", name)
+		if _, err := repo.References.Create(refName, branch.Target(), false, msg); err != nil {
+			return "", fmt.Errorf("could not archive '%s': %w", name, err)
+		}
+	}
+
+	if err := branch.Delete(); err != nil {
+		return "", fmt.Errorf("could not delete '%s': %w", name, err)
+	}
+	return refName, nil
+}
+
+// setArchiveDescription stores the description of a ref archive, or removes
+// it when empty.
+func setArchiveDescription(repo *git.Repository, name string, description string) error {
+	config, err := repo.Config()
+	if err != nil {
+		return err
+	}
+
+	if description == "" {
+		err = config.Delete(ArchiveDescriptionKey(name))
+		if git.IsErrorCode(err, git.ErrorCodeNotFound) {
+			return nil
+		}
+		return err
+	}
+	return config.SetString(ArchiveDescriptionKey(name), description)
+}
+
+// archiveDescription returns the branch description kept by the archive ref:
+// the tag message of a tag archive, or ArchiveDescriptionKey.
+func archiveDescription(repo *git.Repository, ref *git.Reference, name string) string {
+	if tag, err := repo.LookupTag(ref.Target()); err == nil {
+		return tagDescription(tag.Message())
+	}
+
+	config, err := repo.Config()
+	if err != nil {
+		return ""
+	}
+	description, _ := config.LookupString(ArchiveDescriptionKey(name))
+	return description
+}
+
+// archiveRef returns the archive ref of the branch name, or nil.
+func archiveRef(repo *git.Repository, name string) *git.Reference {
+	for _, prefix := range []string{ArchivePrefix, ArchiveTagPrefix} {
+		if ref, err := repo.References.Lookup(prefix + name); err == nil {
+			return ref
+		}
+	}
+	return nil
+}
+
+// MatchBranches returns the local branches matching the glob pattern, or the
+// branch of that name when it has no wildcard.
+func MatchBranches(repo *git.Repository, pattern string) ([]*git.Branch, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s'", pattern)
+	}
+
+	branch_iterator, err := repo.NewBranchIterator(git.BranchLocal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches for '%s': %w", repo.Workdir(), err)
+	}
+	defer branch_iterator.Free()
+
+	branches := []*git.Branch{}
+	err = branch_iterator.ForEach(func(branch *git.Branch, _ git.BranchType) error {
+		name, err := branch.Name()
+		if err != nil {
+			return err
+		}
+		if matched, _ := path.Match(pattern, name); matched {
+			branches = append(branches, branch)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+
+	if len(branches) == 0 {
+		return nil, fmt.Errorf("no branch matches '%s'", pattern)
+	}
+	return branches, nil
+}
+
+// ArchivedStatuses compares every archived branch against the base, oldest
+// first, with the description the archive kept.
+func ArchivedStatuses(repo *git.Repository, baseBranch string) ([]BranchStatus, error) {
+	base_oid, err := LookupBaseOid(repo, baseBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []BranchStatus{}
+
+	for _, prefix := range []string{ArchivePrefix, ArchiveTagPrefix} {
+		iterator, err := repo.NewReferenceIteratorGlob(prefix + "*")
+		if err != nil {
+			return nil, fmt.Errorf("could not list %s: %w", prefix, err)
+		}
+
+		for {
+			ref, err := iterator.Next()
+			if git.IsErrorCode(err, git.ErrorCodeIterOver) {
+				break
+			}
+			if err != nil {
+				iterator.Free()
+				return nil, fmt.Errorf("could not list %s: %w", prefix, err)
+			}
+
+			status, err := archivedStatus(repo, ref, prefix, base_oid)
+			if err != nil {
+				iterator.Free()
+				return nil, err
+			}
+			statuses = append(statuses, status)
+		}
+		iterator.Free()
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].When.Before(statuses[j].When)
+	})
+
+	return statuses, nil
+}
+
+func archivedStatus(repo *git.Repository, ref *git.Reference, prefix string, base_oid *git.Oid) (BranchStatus, error) {
+	s := BranchStatus{Name: strings.TrimPrefix(ref.Name(), prefix)}
+
+	object, err := ref.Peel(git.ObjectCommit)
+	if err != nil {
+		return s, fmt.Errorf("could not resolve '%s': %w", ref.Name(), err)
+	}
+	defer object.Free()
+
+	commit, err := object.AsCommit()
+	if err != nil {
+		return s, err
+	}
+
+	s.Oid = commit.Id().String()
+	s.When = commit.Committer().When
+	s.Author = commit.Author().Name
+
+	s.Description = FirstLine(archiveDescription(repo, ref, s.Name))
+
+	s.Ahead, s.Behind, err = repo.AheadBehind(commit.Id(), base_oid)
+	if err != nil {
+		return s, fmt.Errorf("error getting ahead/behind of '%s' against '%s': %w", s.Oid, base_oid.String(), err)
+	}
+	s.IsMerged = s.Ahead == 0
+	s.IsStale = s.When.Before(time.Now().Add(-StaleAfter))
+
+	return s, nil
+}
+
+// tagDescription is the branch description kept after the first line of an
+// archive tag message.
+func tagDescription(message string) string {
+	parts := strings.SplitN(message, "\n\n", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// Unarchive recreates the archived branch at its archived commit, restoring its
+// description, and deletes the archive.
+func Unarchive(repo *git.Repository, name string) (*git.Branch, error) {
+	ref := archiveRef(repo, name)
+	if ref == nil {
+		return nil, fmt.Errorf("'%s' is not archived", name)
+	}
+
+	if _, err := repo.LookupBranch(name, git.BranchLocal); err == nil {
+		return nil, fmt.Errorf("branch '%s' already exists", name)
+	}
+
+	object, err := ref.Peel(git.ObjectCommit)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve '%s': %w", ref.Name(), err)
+	}
+	defer object.Free()
+
+	commit, err := object.AsCommit()
+	if err != nil {
+		return nil, err
+	}
+
+	branch, err := repo.CreateBranch(name, commit, false)
+	if err != nil {
+		return nil, fmt.Errorf("could not create branch '%s': %w", name, err)
+	}
+
+	if description := archiveDescription(repo, ref, name); description != "" {
+		if err := SetBranchDescription(repo, name, description); err != nil {
+			return branch, err
+		}
+	}
+
+	if err := ref.Delete(); err != nil {
+		return branch, fmt.Errorf("could not delete '%s': %w", ref.Name(), err)
+	}
+	return branch, setArchiveDescription(repo, name, "")
+}
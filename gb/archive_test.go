@@ -0,0 +1,88 @@
+package gb
+
+import (
+	"context"
+	"testing"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+func TestArchive(t *testing.T) {
+	f := newListFixture(t)
+	if err := SetBranchDescription(f.repo, "feature", "an experiment"); err != nil {
+		t.Fatal(err)
+	}
+	tip := mustTarget(t, f, "feature")
+
+	branches, err := MatchBranches(f.repo, "feat*")
+	if err != nil || len(branches) != 1 {
+		t.Fatalf("got %d branches, %v", len(branches), err)
+	}
+
+	ref, err := ArchiveBranch(f.repo, branches[0], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref != ArchiveTagPrefix+"feature" {
+		t.Errorf("got %s", ref)
+	}
+
+	if err := SetBranchDescription(f.repo, "merged", "done"); err != nil {
+		t.Fatal(err)
+	}
+	merged, err := f.repo.LookupBranch("merged", git.BranchLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ArchiveBranch(f.repo, merged, false); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := List(context.Background(), f.repo, f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].Name != "main" {
+		t.Errorf("archived branches are listed: %v", statuses)
+	}
+
+	archived, err := ArchivedStatuses(f.repo, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := byName(archived)
+	if len(got) != 2 {
+		t.Fatalf("got %d archived branches, want 2", len(got))
+	}
+	if feature := got["feature"]; feature.Ahead != 2 || feature.Behind != 1 || feature.Description != "an experiment" {
+		t.Errorf("feature: got ahead=%d behind=%d description=%q", feature.Ahead, feature.Behind, feature.Description)
+	}
+	if !got["merged"].IsMerged || got["merged"].Description != "done" {
+		t.Errorf("merged: got merged=%v description=%q, want merged with its description", got["merged"].IsMerged, got["merged"].Description)
+	}
+
+	branch, err := Unarchive(f.repo, "feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !branch.Target().Equal(tip) {
+		t.Error("feature was not restored at its tip")
+	}
+	if got := BranchDescription(f.repo, "feature"); got != "an experiment" {
+		t.Errorf("description: got %q", got)
+	}
+	if _, err := f.repo.References.Lookup(ArchiveTagPrefix + "feature"); err == nil {
+		t.Error("the archive tag was not deleted")
+	}
+
+	if _, err := Unarchive(f.repo, "feature"); err == nil {
+		t.Error("expected an error for a branch that is not archived")
+	}
+
+	if _, err := Unarchive(f.repo, "merged"); err != nil {
+		t.Fatal(err)
+	}
+	if got := BranchDescription(f.repo, "merged"); got != "done" {
+		t.Errorf("merged description: got %q, want done", got)
+	}
+}
@@ -0,0 +1,130 @@
+package gb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// CacheFile is the name of the comparison cache inside the git directory.
+const CacheFile = "go_gb_cache.json"
+
+// CachePath returns the location of the comparison cache of the repository.
+func CachePath(repo *git.Repository) string {
+	return filepath.Join(repo.Path(), CacheFile)
+}
+
+// CacheStore keeps comparison results by CacheKey. A key only depends on the
+// base and branch tips, so entries never go stale.
+type CacheStore map[string]*Comparison
+
+// NewCacheStore reads the cache at path. A missing or unreadable cache gives
+// an empty store.
+func NewCacheStore(path string) CacheStore {
+	bits, err := ioutil.ReadFile(path)
+	if err != nil {
+		// no-op: the cache will be written on exit.
+	}
+
+	y := make(CacheStore)
+	_ = json.Unmarshal(bits, &y)
+
+	return y
+}
+
+// Add caches a copy of the executed comparison, so later changes that don't
+// come from the commit graph, such as pull requests, are not cached.
+func (store CacheStore) Add(c *Comparison) {
+	cached := *c
+	store[c.CacheKey()] = &cached
+}
+
+// AddConflicts caches the predicted conflicts of the comparison.
+func (store CacheStore) AddConflicts(c *Comparison) {
+	if cached := store[c.CacheKey()]; cached != nil {
+		cached.ConflictsChecked = c.ConflictsChecked
+		cached.Conflicts = c.Conflicts
+	}
+}
+
+func (store *CacheStore) WriteToFile(path string) error {
+	b, err := json.Marshal(store)
+	if err != nil {
+		return fmt.Errorf("could not save cache to file: %w", err)
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// ProviderCacheFile is the name of the hosting provider cache inside the git
+// directory, beside the comparison cache.
+const ProviderCacheFile = "go_gb_provider_cache.json"
+
+// ProviderCachePath returns the location of the hosting provider cache of the
+// repository.
+func ProviderCachePath(repo *git.Repository) string {
+	return filepath.Join(repo.Path(), ProviderCacheFile)
+}
+
+// ProviderCacheEntry is a cached provider response. A zero Expires never
+// expires.
+type ProviderCacheEntry struct {
+	Expires time.Time
+	Value   json.RawMessage
+}
+
+// ProviderCache keeps hosting provider responses until their TTL runs out.
+type ProviderCache map[string]*ProviderCacheEntry
+
+// NewProviderCache reads the cache at path, dropping expired entries. A
+// missing or unreadable cache gives an empty cache.
+func NewProviderCache(path string) ProviderCache {
+	bits, _ := ioutil.ReadFile(path)
+
+	cache := make(ProviderCache)
+	_ = json.Unmarshal(bits, &cache)
+
+	now := time.Now()
+	for key, entry := range cache {
+		if entry == nil || !entry.Expires.IsZero() && now.After(entry.Expires) {
+			delete(cache, key)
+		}
+	}
+
+	return cache
+}
+
+// Get decodes the entry at key into v and reports whether there was one.
+func (cache ProviderCache) Get(key string, v interface{}) bool {
+	entry := cache[key]
+	if entry == nil {
+		return false
+	}
+	return json.Unmarshal(entry.Value, v) == nil
+}
+
+// Set stores v at key for ttl, or forever when ttl is 0.
+func (cache ProviderCache) Set(key string, v interface{}, ttl time.Duration) error {
+	bits, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	entry := &ProviderCacheEntry{Value: bits}
+	if ttl > 0 {
+		entry.Expires = time.Now().Add(ttl)
+	}
+	cache[key] = entry
+	return nil
+}
+
+func (cache ProviderCache) WriteToFile(path string) error {
+	b, err := json.Marshal(cache)
+	if err != nil {
+		return fmt.Errorf("could not save provider cache to file: %w", err)
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
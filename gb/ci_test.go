@@ -0,0 +1,112 @@
+package gb
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGitHubCommitStatus(t *testing.T) {
+	server := standIn(t, map[string]string{
+		"/repos/acme/app/commits/aaa/status": `{"state": "success", "total_count": 2}`,
+		"/repos/acme/app/commits/bbb/status": `{"state": "error", "total_count": 1}`,
+		"/repos/acme/app/commits/ccc/status": `{"state": "pending", "total_count": 1}`,
+		"/repos/acme/app/commits/ddd/status": `{"state": "pending", "total_count": 0}`,
+	}, "Authorization", "")
+
+	github := NewGitHub(server.URL, "acme/app", "")
+	for oid, want := range map[string]string{"aaa": CISuccess, "bbb": CIFailure, "ccc": CIPending, "ddd": ""} {
+		if got, err := github.CommitStatus(oid); err != nil || got != want {
+			t.Errorf("%s: got %q, %v, want %q", oid, got, err, want)
+		}
+	}
+}
+
+func TestGitLabCommitStatus(t *testing.T) {
+	server := standIn(t, map[string]string{
+		"/projects/acme%2Fapp/repository/commits/aaa": `{"last_pipeline": {"status": "failed"}}`,
+		"/projects/acme%2Fapp/repository/commits/bbb": `{"last_pipeline": {"status": "running"}}`,
+		"/projects/acme%2Fapp/repository/commits/ccc": `{"last_pipeline": null}`,
+	}, "PRIVATE-TOKEN", "")
+
+	gitlab := NewGitLab(server.URL, "acme/app", "")
+	for oid, want := range map[string]string{"aaa": CIFailure, "bbb": CIPending, "ccc": ""} {
+		if got, err := gitlab.CommitStatus(oid); err != nil || got != want {
+			t.Errorf("%s: got %q, %v, want %q", oid, got, err, want)
+		}
+	}
+}
+
+func TestCIFailing(t *testing.T) {
+	f := newListFixture(t)
+
+	statuses, err := List(context.Background(), f.repo, f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tips := byName(statuses)
+
+	provider := &fakeProvider{statuses: map[string]string{
+		tips["feature"].Oid: CIFailure,
+		tips["merged"].Oid:  CIPending,
+	}}
+
+	opts := f.options()
+	opts.Provider = provider
+	opts.CI = true
+	opts.CIFailing = true
+	statuses, err = List(context.Background(), f.repo, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := byName(statuses)
+	if len(statuses) != 2 || got["feature"].CI != CIFailure {
+		t.Errorf("--ci=failing kept %+v, want feature and main", statuses)
+	}
+	if provider.calls != 3 {
+		t.Errorf("got %d lookups, want 3", provider.calls)
+	}
+
+	// Only the pending status is looked up again once it expires; terminal
+	// ones are cached forever.
+	cache := NewProviderCache(ProviderCachePath(f.repo))
+	if !cache[provider.Name()+" ci "+tips["feature"].Oid].Expires.IsZero() {
+		t.Error("failure was cached with a TTL")
+	}
+	if cache[provider.Name()+" ci "+tips["merged"].Oid].Expires.IsZero() {
+		t.Error("pending status was cached forever")
+	}
+}
+
+func TestCommitStatusLookupFailure(t *testing.T) {
+	f := newListFixture(t)
+
+	statuses, err := List(context.Background(), f.repo, f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tips := byName(statuses)
+
+	provider := &fakeProvider{
+		statuses: map[string]string{tips["merged"].Oid: CISuccess},
+		failing:  map[string]bool{tips["feature"].Oid: true},
+	}
+
+	opts := f.options()
+	opts.Provider = provider
+	opts.CI = true
+	statuses, err = List(context.Background(), f.repo, opts)
+	var partial *PartialError
+	if !errors.As(err, &partial) || len(partial.Errors) != 1 || partial.Errors[0].Branch != "feature" {
+		t.Fatalf("got %v, want feature to fail alone", err)
+	}
+	if got := byName(statuses)["merged"].CI; got != CISuccess {
+		t.Errorf("merged: got %q, want %q", got, CISuccess)
+	}
+
+	cache := NewProviderCache(ProviderCachePath(f.repo))
+	if _, ok := cache[provider.Name()+" ci "+tips["merged"].Oid]; !ok {
+		t.Error("the successful lookup was not cached")
+	}
+}
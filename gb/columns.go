@@ -0,0 +1,224 @@
+package gb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Columns are the fields of a BranchStatus available to the machine readable
+// formats, in their default order.
+var Columns = []string{
+	"name",
+	"oid",
+	"when",
+	"ahead",
+	"behind",
+	"merged",
+	"stale",
+	"head",
+	"base",
+	"parent",
+	"parent_ahead",
+	"parent_behind",
+	"description",
+	"conflicts",
+	"pr",
+	"pr_state",
+	"review",
+	"ci",
+	"staged",
+	"unstaged",
+	"untracked",
+	"stashes",
+	"error",
+}
+
+// ParseColumns splits a comma-separated list of columns, checking each one.
+// An empty list selects every column.
+func ParseColumns(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return Columns, nil
+	}
+
+	columns := []string{}
+	for _, column := range strings.Split(list, ",") {
+		column = strings.TrimSpace(column)
+		if !isColumn(column) {
+			return nil, fmt.Errorf("unknown column '%s', expected one of %s", column, strings.Join(Columns, ", "))
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+func isColumn(name string) bool {
+	for _, column := range Columns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Value returns the column of the status as a string, bool, int, []string or
+// nil, suitable for encoding to JSON. Conflicts are nil until they were
+// checked, the change counts are nil unless the branch is checked out, and the
+// error is nil unless the branch could not be compared.
+func (s BranchStatus) Value(column string) interface{} {
+	switch column {
+	case "name":
+		return s.Name
+	case "oid":
+		return s.Oid
+	case "when":
+		if s.When.IsZero() {
+			return nil
+		}
+		return s.When.Format(time.RFC3339)
+	case "ahead":
+		return s.Ahead
+	case "behind":
+		return s.Behind
+	case "merged":
+		return s.IsMerged
+	case "stale":
+		return s.IsStale
+	case "head":
+		return s.IsHead
+	case "base":
+		return s.IsBase
+	case "parent":
+		return s.Parent
+	case "parent_ahead":
+		return s.ParentAhead
+	case "parent_behind":
+		return s.ParentBehind
+	case "description":
+		return s.Description
+	case "conflicts":
+		if !s.ConflictsChecked {
+			return nil
+		}
+		if s.Conflicts == nil {
+			return []string{}
+		}
+		return s.Conflicts
+	case "pr":
+		if s.PullRequest == nil {
+			return nil
+		}
+		return s.PullRequest.Number
+	case "pr_state":
+		if s.PullRequest == nil {
+			return nil
+		}
+		return s.PullRequest.State
+	case "review":
+		if s.PullRequest == nil || s.PullRequest.Review == "" {
+			return nil
+		}
+		return s.PullRequest.Review
+	case "ci":
+		if s.CI == "" {
+			return nil
+		}
+		return s.CI
+	case "staged", "unstaged", "untracked":
+		if s.Dirty == nil {
+			return nil
+		}
+		switch column {
+		case "staged":
+			return s.Dirty.Staged
+		case "unstaged":
+			return s.Dirty.Unstaged
+		default:
+			return s.Dirty.Untracked
+		}
+	case "stashes":
+		return s.Stashes
+	case "error":
+		if s.Err == nil {
+			return nil
+		}
+		return s.Err.Err.Error()
+	}
+	return nil
+}
+
+// Text returns the column of the status as it is written to CSV and TSV
+// cells. Conflicting paths are separated by semicolons and nil values are
+// empty.
+func (s BranchStatus) Text(column string) string {
+	switch value := s.Value(column).(type) {
+	case string:
+		return value
+	case int:
+		return strconv.Itoa(value)
+	case bool:
+		return strconv.FormatBool(value)
+	case []string:
+		return strings.Join(value, ";")
+	}
+	return ""
+}
+
+// WriteJSON writes an array with one object per status, keeping the keys in
+// the order of the columns.
+func WriteJSON(w io.Writer, columns []string, statuses []BranchStatus) error {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+
+	for i, status := range statuses {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  ")
+		if err := status.writeJSON(&buf, columns); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteString("\n]\n")
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// WriteJSON writes the status as a single object with the given columns.
+func (s BranchStatus) WriteJSON(w io.Writer, columns []string) error {
+	var buf bytes.Buffer
+	if err := s.writeJSON(&buf, columns); err != nil {
+		return err
+	}
+
+	buf.WriteString("\n")
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func (s BranchStatus) writeJSON(buf *bytes.Buffer, columns []string) error {
+	buf.WriteString("{")
+
+	for i, column := range columns {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+
+		key, _ := json.Marshal(column)
+		value, err := json.Marshal(s.Value(column))
+		if err != nil {
+			return err
+		}
+		buf.Write(key)
+		buf.WriteString(": ")
+		buf.Write(value)
+	}
+
+	buf.WriteString("}")
+	return nil
+}
@@ -0,0 +1,49 @@
+package gb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseColumns(t *testing.T) {
+	columns, err := ParseColumns("")
+	if err != nil || !reflect.DeepEqual(columns, Columns) {
+		t.Errorf("got %v, %v, want every column", columns, err)
+	}
+
+	columns, err = ParseColumns("behind, name")
+	if err != nil || !reflect.DeepEqual(columns, []string{"behind", "name"}) {
+		t.Errorf("got %v, %v, want behind and name", columns, err)
+	}
+
+	if _, err := ParseColumns("name,size"); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+}
+
+func TestColumnText(t *testing.T) {
+	s := BranchStatus{
+		Name:             "fix,\"quoted\"",
+		Ahead:            3,
+		IsMerged:         true,
+		ConflictsChecked: true,
+		Conflicts:        []string{"a.txt", "b.txt"},
+	}
+
+	for column, want := range map[string]string{
+		"name":      "fix,\"quoted\"",
+		"ahead":     "3",
+		"merged":    "true",
+		"when":      "",
+		"conflicts": "a.txt;b.txt",
+		"error":     "",
+	} {
+		if got := s.Text(column); got != want {
+			t.Errorf("%s: got %q, want %q", column, got, want)
+		}
+	}
+
+	if value := (BranchStatus{}).Value("conflicts"); value != nil {
+		t.Errorf("unchecked conflicts: got %v, want nil", value)
+	}
+}
@@ -0,0 +1,57 @@
+package gb
+
+import (
+	"fmt"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// CommitSummary is the first line and author of a commit.
+type CommitSummary struct {
+	Oid     string
+	Summary string
+	Author  string
+	When    time.Time
+}
+
+// UniqueCommits returns up to limit commits reachable from oid but not from
+// base_oid, newest first, along with the total count. A limit of 0 returns
+// every commit.
+func UniqueCommits(repo *git.Repository, base_oid, oid *git.Oid, limit int) ([]CommitSummary, int, error) {
+	walk, err := repo.Walk()
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not walk commits: %w", err)
+	}
+	defer walk.Free()
+
+	walk.Sorting(git.SortTime)
+
+	if err := walk.Push(oid); err != nil {
+		return nil, 0, fmt.Errorf("could not walk from '%s': %w", oid.String(), err)
+	}
+	if err := walk.Hide(base_oid); err != nil {
+		return nil, 0, fmt.Errorf("could not hide '%s': %w", base_oid.String(), err)
+	}
+
+	commits := []CommitSummary{}
+	total := 0
+	err = walk.Iterate(func(commit *git.Commit) bool {
+		total++
+		if limit == 0 || len(commits) < limit {
+			author := commit.Author()
+			commits = append(commits, CommitSummary{
+				Oid:     commit.Id().String(),
+				Summary: commit.Summary(),
+				Author:  author.Name,
+				When:    author.When,
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not walk from '%s': %w", oid.String(), err)
+	}
+
+	return commits, total, nil
+}
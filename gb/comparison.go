@@ -0,0 +1,322 @@
+package gb
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// StaleAfter is how long a branch can go without commits before it is
+// considered stale.
+const StaleAfter = 14 * 24 * time.Hour
+
+type Comparison struct {
+	Repo    *git.Repository
+	BaseOid *git.Oid
+	Branch  *git.Branch
+	Oid     *git.Oid
+
+	IsMerged bool
+	Ahead    int
+	Behind   int
+
+	ConflictsChecked bool
+	Conflicts        []string
+
+	Parent        *Comparison `json:"-"`
+	ParentBaseOid *git.Oid    `json:"-"`
+	ParentAhead   int         `json:"-"`
+	ParentBehind  int         `json:"-"`
+	Depth         int         `json:"-"`
+
+	// PullRequest and CI are set by SetPullRequests and SetCommitStatuses.
+	PullRequest *PullRequest `json:"-"`
+	CI          string       `json:"-"`
+
+	// Dirty and Stashes are set by SetWorktreeStatus. Dirty is nil unless
+	// the branch is checked out in a worktree.
+	Dirty   *Dirtiness `json:"-"`
+	Stashes int        `json:"-"`
+
+	// Err is set when the branch could not be compared. The other fields
+	// are then unreliable.
+	Err *BranchError `json:"-"`
+
+	name   string
+	isHead bool
+	commit *git.Commit
+
+	// localBase is LocalBase of the base named base, resolved once by
+	// resolveBase.
+	base      string
+	localBase string
+}
+
+// NewComparison prepares the comparison of the branch against the base,
+// filled in from the cache when possible. When the branch can't be read, the
+// comparison is still returned, with Err set.
+func NewComparison(repo *git.Repository, base_oid *git.Oid, branch *git.Branch, store CacheStore) (*Comparison, error) {
+	c := new(Comparison)
+
+	c.Repo = repo
+	c.BaseOid = base_oid
+
+	c.Branch = branch
+	c.Oid = branch.Target()
+
+	var err error
+	c.name, err = branch.Name()
+	if err != nil {
+		c.name = branch.Shorthand()
+		return c, c.fail(fmt.Errorf("can't get branch name: %w", err))
+	}
+
+	if c.Oid == nil {
+		return c, c.fail(fmt.Errorf("'%s' is not a direct reference", branch.Reference.Name()))
+	}
+
+	c.isHead, err = branch.IsHead()
+	if err != nil {
+		return c, c.fail(fmt.Errorf("can't get IsHead: %w", err))
+	}
+
+	c.commit, err = repo.LookupCommit(c.Oid)
+	if err != nil {
+		return c, c.fail(fmt.Errorf("could not lookup commit '%s': %w", c.Oid.String(), err))
+	}
+
+	cache := store[c.CacheKey()]
+
+	if cache != nil {
+		c.Ahead = cache.Ahead
+		c.Behind = cache.Behind
+		c.IsMerged = cache.IsMerged
+		c.ConflictsChecked = cache.ConflictsChecked
+		c.Conflicts = cache.Conflicts
+	} else {
+		c.IsMerged = false
+		c.Ahead = -1
+		c.Behind = -1
+	}
+
+	return c, nil
+}
+
+// fail records the error of the branch and returns it.
+func (c *Comparison) fail(err error) *BranchError {
+	c.Err = &BranchError{Branch: c.name, Err: err}
+	return c.Err
+}
+
+func (c *Comparison) Name() string {
+	return c.name
+}
+
+func (c *Comparison) IsHead() bool {
+	return c.isHead
+}
+
+func (c *Comparison) Commit() *git.Commit {
+	return c.commit
+}
+
+func (c *Comparison) Description() string {
+	return FirstLine(BranchDescription(c.Repo, c.Name()))
+}
+
+// Matches reports whether the branch name or its full description matches the
+// pattern.
+func (c *Comparison) Matches(pattern *regexp.Regexp) bool {
+	return pattern.MatchString(c.Name()) ||
+		pattern.MatchString(BranchDescription(c.Repo, c.Name()))
+}
+
+func (c *Comparison) When() time.Time {
+	if c.Commit() == nil {
+		return time.Time{}
+	}
+
+	sig := c.Commit().Committer()
+	return sig.When
+}
+
+// IsBase reports whether the branch is the base, or the local branch of a
+// remote base.
+func (c *Comparison) IsBase(baseBranch string) bool {
+	if c.Name() == baseBranch {
+		return true
+	}
+	if baseBranch == c.base {
+		return c.Name() == c.localBase
+	}
+	return c.Name() == LocalBase(c.Repo, baseBranch)
+}
+
+// IsStale reports whether the last commit is older than StaleAfter.
+func (c *Comparison) IsStale() bool {
+	return c.When().Before(time.Now().Add(-StaleAfter))
+}
+
+func (c *Comparison) CacheKey() string {
+	strs := []string{c.BaseOid.String(), c.Oid.String()}
+	return strings.Join(strs, "..")
+}
+
+func (c *Comparison) SetIsMerged() error {
+	if c.Oid.String() == c.BaseOid.String() {
+		c.IsMerged = true
+	} else {
+		merged, err := c.Repo.DescendantOf(c.BaseOid, c.Oid)
+		if err != nil {
+			return fmt.Errorf("could not get descendant of '%s' and '%s': %w", c.BaseOid.String(), c.Oid.String(), err)
+		}
+		c.IsMerged = merged
+	}
+	return nil
+}
+
+func (c *Comparison) SetAheadBehind() error {
+	var err error
+	c.Ahead, c.Behind, err = c.Repo.AheadBehind(c.Oid, c.BaseOid)
+	if err != nil {
+		return fmt.Errorf("error getting ahead/behind of '%s' against '%s': %w", c.Oid.String(), c.BaseOid.String(), err)
+	}
+	return nil
+}
+
+// Execute computes the merged state and ahead/behind counts unless they came
+// from the cache. A failure is recorded in Err and returned.
+func (c *Comparison) Execute() error {
+	if c.Err != nil {
+		return c.Err
+	}
+
+	if c.Ahead > -1 && c.Behind > -1 {
+		return nil
+	}
+
+	if err := c.SetIsMerged(); err != nil {
+		return c.fail(err)
+	}
+	if err := c.SetAheadBehind(); err != nil {
+		return c.fail(err)
+	}
+	return nil
+}
+
+type Comparisons []*Comparison
+
+// NewComparisons builds a comparison against the base for every branch of the
+// iterator, sorted by the date of their last commit. Branches that can't be
+// read are included with Err set.
+func NewComparisons(repo *git.Repository, branch_iterator *git.BranchIterator, base_oid *git.Oid, store CacheStore) (Comparisons, error) {
+	comparisons := make(Comparisons, 0)
+
+	// type BranchIteratorFunc func(*Branch, BranchType) error
+	err := branch_iterator.ForEach(func(branch *git.Branch, btype git.BranchType) error {
+		comp, _ := NewComparison(repo, base_oid, branch, store)
+		comparisons = append(comparisons, comp)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+
+	sort.Sort(ComparisonsByWhen(comparisons))
+
+	return comparisons, nil
+}
+
+// LocalComparisons compares every local branch of the repository against the
+// base.
+func LocalComparisons(repo *git.Repository, base_oid *git.Oid, store CacheStore) (Comparisons, error) {
+	branch_iterator, err := repo.NewBranchIterator(git.BranchLocal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches for '%s': %w", repo.Workdir(), err)
+	}
+	defer branch_iterator.Free()
+
+	return NewComparisons(repo, branch_iterator, base_oid, store)
+}
+
+// RemoteComparisons compares every remote-tracking branch of the remote, or of
+// every remote when remoteName is empty, against the base. Symbolic refs such
+// as origin/HEAD are left out.
+func RemoteComparisons(repo *git.Repository, remoteName string, base_oid *git.Oid, store CacheStore) (Comparisons, error) {
+	branch_iterator, err := repo.NewBranchIterator(git.BranchRemote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote branches for '%s': %w", repo.Workdir(), err)
+	}
+	defer branch_iterator.Free()
+
+	comparisons, err := NewComparisons(repo, branch_iterator, base_oid, store)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := ""
+	if remoteName != "" {
+		prefix = remoteName + "/"
+	}
+
+	remote := make(Comparisons, 0)
+	for _, comp := range comparisons {
+		if !strings.HasPrefix(comp.Name(), prefix) || comp.Branch.Type() == git.ReferenceSymbolic {
+			continue
+		}
+		remote = append(remote, comp)
+	}
+	return remote, nil
+}
+
+// resolveBase looks up the local branch of the base once for every
+// comparison, so IsBase doesn't have to.
+func (cs Comparisons) resolveBase(repo *git.Repository, baseBranch string) {
+	localBase := LocalBase(repo, baseBranch)
+	for _, comp := range cs {
+		comp.base = baseBranch
+		comp.localBase = localBase
+	}
+}
+
+// Execute runs every comparison and stores the successful ones in the cache.
+// Failures are recorded on each comparison; see Errors.
+func (cs Comparisons) Execute(store CacheStore) {
+	for _, comp := range cs {
+		if comp.Execute() == nil {
+			store.Add(comp)
+		}
+	}
+}
+
+func (cs Comparisons) MaxBranchLength() int {
+	max := 30
+
+	for _, comp := range cs {
+		// Each nesting level of BranchStatus.TreeName takes two columns.
+		length := utf8.RuneCountInString(comp.Name()) + 2*comp.Depth
+		if length > max {
+			max = length
+		}
+	}
+	return max
+}
+
+type ComparisonsByWhen Comparisons
+
+func (a ComparisonsByWhen) Len() int {
+	return len(a)
+}
+
+func (a ComparisonsByWhen) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+func (a ComparisonsByWhen) Less(i, j int) bool {
+	return a[i].When().Unix() < a[j].When().Unix()
+}
@@ -0,0 +1,89 @@
+package gb
+
+import (
+	"fmt"
+	"sort"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// MergeConflicts does an in-memory three-way merge of two commits and returns
+// the paths that would conflict. Nothing is written to the index or worktree.
+func MergeConflicts(repo *git.Repository, ours, theirs *git.Oid) ([]string, error) {
+	ourCommit, err := repo.LookupCommit(ours)
+	if err != nil {
+		return nil, err
+	}
+
+	theirCommit, err := repo.LookupCommit(theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := repo.MergeCommits(ourCommit, theirCommit, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer index.Free()
+
+	return conflictPaths(index)
+}
+
+// conflictPaths lists the conflicting paths of an index, sorted.
+func conflictPaths(index *git.Index) ([]string, error) {
+	paths := []string{}
+	if !index.HasConflicts() {
+		return paths, nil
+	}
+
+	iterator, err := index.ConflictIterator()
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Free()
+
+	for {
+		conflict, err := iterator.Next()
+		if git.IsErrorCode(err, git.ErrorCodeIterOver) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, conflictPath(conflict))
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func conflictPath(conflict git.IndexConflict) string {
+	for _, entry := range []*git.IndexEntry{conflict.Our, conflict.Their, conflict.Ancestor} {
+		if entry != nil {
+			return entry.Path
+		}
+	}
+	return ""
+}
+
+// SetConflicts predicts whether the branch merges cleanly into the base.
+// Merged branches are skipped. A failure is recorded in Err and returned.
+func (c *Comparison) SetConflicts() error {
+	if c.Err != nil {
+		return c.Err
+	}
+
+	if c.ConflictsChecked || c.IsMerged {
+		return nil
+	}
+
+	paths, err := MergeConflicts(c.Repo, c.BaseOid, c.Oid)
+	if err != nil {
+		return c.fail(fmt.Errorf("could not merge '%s' into '%s': %w", c.Oid.String(), c.BaseOid.String(), err))
+	}
+
+	c.Conflicts = paths
+	c.ConflictsChecked = true
+	return nil
+}
@@ -0,0 +1,44 @@
+package gb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestConflicts(t *testing.T) {
+	f := newFixture(t)
+
+	c1 := f.commit(nil, "c1", map[string]string{"a.txt": "base\n", "b.txt": "base\n"})
+
+	conflicting := f.commit(c1, "branch change", map[string]string{"a.txt": "branch\n"})
+	f.branch("conflicting", conflicting)
+
+	clean := f.commit(c1, "other file", map[string]string{"c.txt": "new\n"})
+	f.branch("clean", clean)
+
+	c2 := f.commit(c1, "main change", map[string]string{"a.txt": "main\n"})
+	f.branch("main", c2)
+	f.checkout("main")
+
+	opts := f.options()
+	opts.Conflicts = true
+	statuses, err := List(context.Background(), f.repo, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := byName(statuses)
+
+	if c := got["conflicting"]; !c.ConflictsChecked || !reflect.DeepEqual(c.Conflicts, []string{"a.txt"}) {
+		t.Errorf("conflicting: checked=%v conflicts=%v, want [a.txt]", c.ConflictsChecked, c.Conflicts)
+	}
+
+	if c := got["clean"]; !c.ConflictsChecked || len(c.Conflicts) != 0 {
+		t.Errorf("clean: checked=%v conflicts=%v, want none", c.ConflictsChecked, c.Conflicts)
+	}
+
+	if c := got["main"]; c.ConflictsChecked {
+		t.Errorf("the base branch should not be checked")
+	}
+}
@@ -0,0 +1,52 @@
+package gb
+
+import (
+	"fmt"
+	"strings"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+func DescriptionKey(branchName string) string {
+	return fmt.Sprintf("branch.%s.description", branchName)
+}
+
+// BranchDescription returns the `branch.<name>.description` value, or an
+// empty string when the branch has none.
+func BranchDescription(repo *git.Repository, branchName string) string {
+	config, err := repo.Config()
+	if err != nil {
+		return ""
+	}
+
+	description, err := config.LookupString(DescriptionKey(branchName))
+	if err != nil {
+		return ""
+	}
+
+	return description
+}
+
+// SetBranchDescription stores the description in the repository config. An
+// empty description removes the key altogether, like `git branch
+// --edit-description` does.
+func SetBranchDescription(repo *git.Repository, branchName, description string) error {
+	config, err := repo.Config()
+	if err != nil {
+		return err
+	}
+
+	if description == "" {
+		err = config.Delete(DescriptionKey(branchName))
+		if git.IsErrorCode(err, git.ErrorCodeNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	return config.SetString(DescriptionKey(branchName), description)
+}
+
+func FirstLine(s string) string {
+	return strings.SplitN(strings.TrimSpace(s), "\n", 2)[0]
+}
@@ -0,0 +1,141 @@
+package gb
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// Dirtiness counts the uncommitted changes of a worktree.
+type Dirtiness struct {
+	Staged    int
+	Unstaged  int
+	Untracked int
+}
+
+// IsDirty reports whether there is any change, untracked files included.
+func (d Dirtiness) IsDirty() bool {
+	return d.Staged+d.Unstaged+d.Untracked > 0
+}
+
+// String lists the non-zero counts, such as "2 staged, 1 untracked".
+func (d Dirtiness) String() string {
+	parts := []string{}
+	if d.Staged > 0 {
+		parts = append(parts, fmt.Sprintf("%d staged", d.Staged))
+	}
+	if d.Unstaged > 0 {
+		parts = append(parts, fmt.Sprintf("%d unstaged", d.Unstaged))
+	}
+	if d.Untracked > 0 {
+		parts = append(parts, fmt.Sprintf("%d untracked", d.Untracked))
+	}
+	return strings.Join(parts, ", ")
+}
+
+const (
+	statusStaged = git.StatusIndexNew | git.StatusIndexModified | git.StatusIndexDeleted |
+		git.StatusIndexRenamed | git.StatusIndexTypeChange
+	statusUnstaged = git.StatusWtModified | git.StatusWtDeleted | git.StatusWtRenamed |
+		git.StatusWtTypeChange | git.StatusConflicted
+)
+
+// Dirtiness counts the staged, unstaged and untracked files of the worktree.
+// A file can be both staged and unstaged.
+func (w Worktree) Dirtiness() (Dirtiness, error) {
+	var d Dirtiness
+
+	repo, err := git.OpenRepository(w.Path)
+	if err != nil {
+		return d, fmt.Errorf("could not open worktree '%s': %w", w.Path, err)
+	}
+	defer repo.Free()
+
+	list, err := repo.StatusList(&git.StatusOptions{
+		Show:  git.StatusShowIndexAndWorkdir,
+		Flags: git.StatusOptIncludeUntracked | git.StatusOptExcludeSubmodules,
+	})
+	if err != nil {
+		return d, fmt.Errorf("could not get status of '%s': %w", w.Path, err)
+	}
+	defer list.Free()
+
+	count, err := list.EntryCount()
+	if err != nil {
+		return d, fmt.Errorf("could not get status of '%s': %w", w.Path, err)
+	}
+
+	for i := 0; i < count; i++ {
+		entry, err := list.ByIndex(i)
+		if err != nil {
+			return d, fmt.Errorf("could not get status of '%s': %w", w.Path, err)
+		}
+
+		if entry.Status&statusStaged != 0 {
+			d.Staged++
+		}
+		if entry.Status&statusUnstaged != 0 {
+			d.Unstaged++
+		}
+		if entry.Status&git.StatusWtNew != 0 {
+			d.Untracked++
+		}
+	}
+
+	return d, nil
+}
+
+var stashMessagePattern = regexp.MustCompile(`^(?:WIP on|On) ([^:]+):`)
+
+// Stashes counts the stash entries created on each branch, from the messages
+// of the stash reflog: "WIP on <branch>: ..." or "On <branch>: <message>".
+func Stashes(repo *git.Repository) (map[string]int, error) {
+	stashes := make(map[string]int)
+
+	err := repo.Stashes.Foreach(func(index int, message string, id *git.Oid) error {
+		if match := stashMessagePattern.FindStringSubmatch(message); match != nil {
+			stashes[match[1]]++
+		}
+		return nil
+	})
+	if err != nil && !git.IsErrorCode(err, git.ErrorCodeNotFound) {
+		return nil, fmt.Errorf("could not list stashes: %w", err)
+	}
+
+	return stashes, nil
+}
+
+// SetWorktreeStatus counts the stashes of every branch, and the uncommitted
+// changes of the branches checked out in a worktree.
+func (cs Comparisons) SetWorktreeStatus(repo *git.Repository) error {
+	stashes, err := Stashes(repo)
+	if err != nil {
+		return err
+	}
+
+	checkedOut := CheckedOut(repo)
+
+	for _, comp := range cs {
+		if comp.Err != nil {
+			continue
+		}
+
+		comp.Stashes = stashes[comp.Name()]
+
+		worktree, ok := checkedOut[comp.Branch.Reference.Name()]
+		if !ok {
+			continue
+		}
+
+		dirtiness, err := worktree.Dirtiness()
+		if err != nil {
+			comp.fail(err)
+			continue
+		}
+		comp.Dirty = &dirtiness
+	}
+
+	return nil
+}
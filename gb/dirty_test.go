@@ -0,0 +1,86 @@
+package gb
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+func (f *fixture) write(path string, content string) {
+	if err := ioutil.WriteFile(filepath.Join(f.dir, path), []byte(content), 0644); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func TestWorktreeStatus(t *testing.T) {
+	f := newListFixture(t)
+	if err := f.repo.CheckoutHead(&git.CheckoutOptions{Strategy: git.CheckoutForce}); err != nil {
+		t.Fatal(err)
+	}
+
+	sig := &git.Signature{Name: "gb", Email: "gb@example.com", When: f.when}
+	f.write("a.txt", "stashed")
+	if _, err := f.repo.Stashes.Save(sig, "wip", git.StashDefault); err != nil {
+		t.Fatal(err)
+	}
+
+	f.write("a.txt", "unstaged")
+	f.write("b.txt", "staged")
+	f.write("c.txt", "untracked")
+
+	index, err := f.repo.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := index.AddByPath("b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := f.options()
+	opts.Worktrees = true
+	statuses, err := List(context.Background(), f.repo, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := byName(statuses)
+
+	main := got["main"]
+	if main.Dirty == nil {
+		t.Fatal("main: no dirtiness")
+	}
+	if want := (Dirtiness{Staged: 1, Unstaged: 1, Untracked: 1}); *main.Dirty != want {
+		t.Errorf("main: got %+v, want %+v", *main.Dirty, want)
+	}
+	if main.Dirty.String() != "1 staged, 1 unstaged, 1 untracked" {
+		t.Errorf("main: got %q", main.Dirty.String())
+	}
+	if main.Stashes != 1 {
+		t.Errorf("main: got %d stashes, want 1", main.Stashes)
+	}
+
+	if feature := got["feature"]; feature.Dirty != nil || feature.Stashes != 0 {
+		t.Errorf("feature: got %+v and %d stashes, want neither", feature.Dirty, feature.Stashes)
+	}
+}
+
+func TestStashMessages(t *testing.T) {
+	for message, want := range map[string]string{
+		"WIP on feature-x: 1a2b3c4 Add things": "feature-x",
+		"On main: before the rebase":           "main",
+		"autostash":                            "",
+	} {
+		got := ""
+		if match := stashMessagePattern.FindStringSubmatch(message); match != nil {
+			got = match[1]
+		}
+		if got != want {
+			t.Errorf("%q: got %q, want %q", message, got, want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package gb
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNoRepository is wrapped by errors about a directory that is not in a
+	// git repository.
+	ErrNoRepository = errors.New("not a git repository")
+
+	// ErrBaseNotFound is wrapped by errors about a base branch that does not
+	// exist.
+	ErrBaseNotFound = errors.New("base branch not found")
+
+	// ErrPolicyViolation is wrapped by errors about a branch name that breaks
+	// the naming policy.
+	ErrPolicyViolation = errors.New("branch name violates the naming policy")
+)
+
+// BranchError is a failure to compare a single branch, such as a corrupt ref
+// or a commit missing from a partial clone. It does not stop the other
+// branches from being compared.
+type BranchError struct {
+	Branch string
+	Err    error
+}
+
+func (e *BranchError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Branch, e.Err)
+}
+
+func (e *BranchError) Unwrap() error {
+	return e.Err
+}
+
+// PartialError is returned along with the results when some branches could
+// not be compared.
+type PartialError struct {
+	Errors []*BranchError
+}
+
+func (e *PartialError) Error() string {
+	if len(e.Errors) == 1 {
+		return "1 branch could not be compared"
+	}
+	return fmt.Sprintf("%d branches could not be compared", len(e.Errors))
+}
+
+// Errors collects the errors of the comparisons into a PartialError, or
+// returns nil when every comparison succeeded.
+func (cs Comparisons) Errors() error {
+	partial := new(PartialError)
+	for _, comp := range cs {
+		if comp.Err != nil {
+			partial.Errors = append(partial.Errors, comp.Err)
+		}
+	}
+
+	if len(partial.Errors) == 0 {
+		return nil
+	}
+	return partial
+}
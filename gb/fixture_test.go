@@ -0,0 +1,146 @@
+package gb
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// fixture is a repository in a temporary directory. Commits are created
+// directly in the object database, so nothing is checked out.
+type fixture struct {
+	t    *testing.T
+	repo *git.Repository
+	dir  string
+	when time.Time
+}
+
+func newFixture(t *testing.T) *fixture {
+	return initFixture(t, false)
+}
+
+// newBareFixture creates a bare repository, for use as a remote.
+func newBareFixture(t *testing.T) *fixture {
+	return initFixture(t, true)
+}
+
+func initFixture(t *testing.T, bare bool) *fixture {
+	dir, err := ioutil.TempDir("", "gb-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	repo, err := git.InitRepository(dir, bare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(repo.Free)
+
+	config, err := repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.SetString("user.name", "gb")
+	config.SetString("user.email", "gb@example.com")
+
+	return &fixture{
+		t:    t,
+		repo: repo,
+		dir:  dir,
+		when: time.Now().Add(-time.Hour),
+	}
+}
+
+// commit creates a commit on top of parent (nil for a root commit) that sets
+// each file to the given content.
+func (f *fixture) commit(parent *git.Oid, message string, files map[string]string) *git.Oid {
+	var builder *git.TreeBuilder
+	var parents []*git.Commit
+	var err error
+
+	if parent == nil {
+		builder, err = f.repo.TreeBuilder()
+	} else {
+		commit := f.lookup(parent)
+		parents = append(parents, commit)
+
+		tree, terr := commit.Tree()
+		if terr != nil {
+			f.t.Fatal(terr)
+		}
+		builder, err = f.repo.TreeBuilderFromTree(tree)
+	}
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	defer builder.Free()
+
+	for path, content := range files {
+		blob, err := f.repo.CreateBlobFromBuffer([]byte(content))
+		if err != nil {
+			f.t.Fatal(err)
+		}
+		if err := builder.Insert(path, blob, git.FilemodeBlob); err != nil {
+			f.t.Fatal(err)
+		}
+	}
+
+	tree_oid, err := builder.Write()
+	if err != nil {
+		f.t.Fatal(err)
+	}
+
+	tree, err := f.repo.LookupTree(tree_oid)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+
+	// Keep commits a minute apart so they sort predictably.
+	f.when = f.when.Add(time.Minute)
+	sig := &git.Signature{Name: "gb", Email: "gb@example.com", When: f.when}
+
+	oid, err := f.repo.CreateCommit("", sig, sig, message, tree, parents...)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return oid
+}
+
+func (f *fixture) lookup(oid *git.Oid) *git.Commit {
+	commit, err := f.repo.LookupCommit(oid)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return commit
+}
+
+func (f *fixture) branch(name string, oid *git.Oid) {
+	if _, err := f.repo.CreateBranch(name, f.lookup(oid), true); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *fixture) checkout(name string) {
+	if err := f.repo.SetHead("refs/heads/" + name); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// options compares against main with a cache inside the fixture.
+func (f *fixture) options() Options {
+	opts := DefaultOptions()
+	opts.Base = "main"
+	return opts
+}
+
+func byName(statuses []BranchStatus) map[string]BranchStatus {
+	m := make(map[string]BranchStatus)
+	for _, status := range statuses {
+		m[status.Name] = status
+	}
+	return m
+}
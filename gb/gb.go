@@ -0,0 +1,120 @@
+// Package gb compares the local branches of a git repository against a base
+// branch: how far ahead and behind each branch is, whether it is merged,
+// whether it would conflict, and how branches stack on top of each other.
+//
+// Every function returns errors instead of exiting, so the package can be
+// used outside of the git-gb command.
+package gb
+
+import (
+	"fmt"
+	"strings"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// FallbackBase is the base branch used when none is given or configured.
+const FallbackBase = "main"
+
+// OpenRepository opens the repository containing dir, searching parent
+// directories like git does.
+func OpenRepository(dir string) (*git.Repository, error) {
+	repo, err := git.OpenRepositoryExtended(dir, 0, "")
+	if err != nil {
+		return nil, fmt.Errorf("%w: '%s': %s", ErrNoRepository, dir, err)
+	}
+	return repo, nil
+}
+
+// RepoPath is the working directory of the repository, or its git directory
+// when it is bare, without a trailing slash.
+func RepoPath(repo *git.Repository) string {
+	path := repo.Workdir()
+	if path == "" {
+		path = repo.Path()
+	}
+	return strings.TrimSuffix(path, "/")
+}
+
+// BaseBranch returns the revision to compare against: the given name, the
+// `init.defaultBranch` value when it exists in this repository, the branch
+// origin/HEAD points to, or FallbackBase. The base can be any revision, such as
+// origin/main, a tag or HEAD~50.
+func BaseBranch(repo *git.Repository, name string) string {
+	if name != "" {
+		return name
+	}
+
+	// init.defaultBranch is the branch of new repositories, which this one
+	// may not have.
+	if config, err := repo.Config(); err == nil {
+		defaultBranch, err := config.LookupString("init.defaultBranch")
+		if err == nil && defaultBranch != "" {
+			if _, err := repo.LookupBranch(defaultBranch, git.BranchLocal); err == nil {
+				return defaultBranch
+			}
+		}
+	}
+
+	if remoteHead := RemoteHead(repo, "origin"); remoteHead != "" {
+		return remoteHead
+	}
+
+	return FallbackBase
+}
+
+// RemoteHead returns the branch the symbolic `refs/remotes/<remote>/HEAD`
+// points to, such as origin/main, or "" when it isn't set.
+func RemoteHead(repo *git.Repository, remoteName string) string {
+	ref, err := repo.References.Lookup("refs/remotes/" + remoteName + "/HEAD")
+	if err != nil || ref.Type() != git.ReferenceSymbolic {
+		return ""
+	}
+	return strings.TrimPrefix(ref.SymbolicTarget(), "refs/remotes/")
+}
+
+// LocalBase returns the local branch standing for the base: the local branch
+// of the same name as a remote base, such as main for origin/main, or the base
+// itself. A base that is no remote-tracking branch is returned as is.
+func LocalBase(repo *git.Repository, baseBranch string) string {
+	if _, err := repo.LookupBranch(baseBranch, git.BranchLocal); err == nil {
+		return baseBranch
+	}
+
+	if _, err := repo.LookupBranch(baseBranch, git.BranchRemote); err != nil {
+		return baseBranch
+	}
+
+	remotes, err := repo.Remotes.List()
+	if err != nil {
+		return baseBranch
+	}
+
+	// Remote names may contain slashes, so the longest matching one wins.
+	local := baseBranch
+	for _, remoteName := range remotes {
+		name := strings.TrimPrefix(baseBranch, remoteName+"/")
+		if name != baseBranch && len(name) < len(local) {
+			local = name
+		}
+	}
+	return local
+}
+
+// LookupBaseOid returns the commit the base revision resolves to. Any revspec
+// works: a local or remote branch, a tag, HEAD~50 or an oid.
+func LookupBaseOid(repo *git.Repository, base string) (*git.Oid, error) {
+	object, err := repo.RevparseSingle(base)
+	if err != nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrBaseNotFound, base)
+	}
+	defer object.Free()
+
+	commit, err := object.Peel(git.ObjectCommit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: '%s' is not a commit", ErrBaseNotFound, base)
+	}
+	defer commit.Free()
+
+	return commit.Id(), nil
+}
@@ -0,0 +1,128 @@
+package gb
+
+import (
+	"context"
+	"testing"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+func TestLookupBaseOid(t *testing.T) {
+	f := newListFixture(t)
+
+	main, err := f.repo.LookupBranch("main", git.BranchLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tip := f.lookup(main.Target())
+
+	if _, err := f.repo.References.Create("refs/remotes/origin/main", tip.ParentId(0), true, ""); err != nil {
+		t.Fatal(err)
+	}
+	tagger := tip.Author()
+	if _, err := f.repo.Tags.Create("v1", tip, tagger, "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for revision, want := range map[string]string{
+		"main":        tip.Id().String(),
+		"origin/main": tip.ParentId(0).String(),
+		"v1":          tip.Id().String(),
+		"HEAD~1":      tip.ParentId(0).String(),
+	} {
+		oid, err := LookupBaseOid(f.repo, revision)
+		if err != nil {
+			t.Errorf("%s: %s", revision, err)
+			continue
+		}
+		if oid.String() != want {
+			t.Errorf("%s: got %s, want %s", revision, oid, want)
+		}
+	}
+}
+
+func TestBaseBranchRemoteHead(t *testing.T) {
+	f := newListFixture(t)
+
+	config, err := f.repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.SetString("init.defaultBranch", "trunk")
+
+	main, err := f.repo.LookupBranch("main", git.BranchLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.repo.References.Create("refs/remotes/origin/main", main.Target(), true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.repo.References.CreateSymbolic("refs/remotes/origin/HEAD", "refs/remotes/origin/main", true, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := BaseBranch(f.repo, ""); got != "origin/main" {
+		t.Errorf("got %q, want origin/main", got)
+	}
+
+	config.SetString("init.defaultBranch", "main")
+	if got := BaseBranch(f.repo, ""); got != "main" {
+		t.Errorf("got %q, want main", got)
+	}
+}
+
+func TestRemoteHeadBaseIsLocalBranch(t *testing.T) {
+	f := newListFixture(t)
+
+	config, err := f.repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.SetString("init.defaultBranch", "trunk")
+
+	if _, err := f.repo.Remotes.Create("origin", "https://example.com/origin.git"); err != nil {
+		t.Fatal(err)
+	}
+
+	main, err := f.repo.LookupBranch("main", git.BranchLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.branch("production", main.Target())
+	if _, err := f.repo.References.Create("refs/remotes/origin/production", main.Target(), true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.repo.References.CreateSymbolic("refs/remotes/origin/HEAD", "refs/remotes/origin/production", true, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	baseBranch := BaseBranch(f.repo, "")
+	if baseBranch != "origin/production" {
+		t.Fatalf("got base %q, want origin/production", baseBranch)
+	}
+	if got := LocalBase(f.repo, baseBranch); got != "production" {
+		t.Errorf("got local base %q, want production", got)
+	}
+
+	opts := DefaultOptions()
+	opts.Merged = true
+	statuses, err := List(context.Background(), f.repo, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := byName(statuses)
+	if production, ok := got["production"]; !ok || !production.IsBase {
+		t.Errorf("production: listed=%v IsBase=%v, want the base", ok, production.IsBase)
+	}
+	if got["main"].IsBase {
+		t.Error("main is not the base")
+	}
+
+	protection, err := NewProtection(f.repo, baseBranch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !protection.Protected("production") {
+		t.Error("production is the base and should be protected")
+	}
+}
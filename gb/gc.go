@@ -0,0 +1,348 @@
+package gb
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// GCRuleKey is the multi-valued git config key of the retention rules of
+// `gb gc`, evaluated in order. A rule is a list of conditions that must all
+// hold and an action: `merged age>14d -> delete`.
+const GCRuleKey = "gb.gc.rule"
+
+// GCJournalFile is where `gb gc --apply` logs its actions, inside the git
+// directory.
+const GCJournalFile = "gb_gc_journal"
+
+// Actions of the retention rules.
+const (
+	ActionKeep    = "keep"
+	ActionDelete  = "delete"
+	ActionArchive = "archive"
+)
+
+// condition tests a comparison in a GCContext.
+type condition func(c *Comparison, ctx GCContext) bool
+
+// Rule is a parsed retention rule. Index is its position in the config,
+// starting at 1.
+type Rule struct {
+	Index  int
+	Text   string
+	Action string
+
+	conditions []condition
+}
+
+// GCContext is what rules are evaluated against besides the comparison.
+type GCContext struct {
+	Now time.Time
+
+	// Me are the user.name and user.email of the repository config, which
+	// `author=me` matches.
+	Me []string
+}
+
+var (
+	flagPattern    = regexp.MustCompile(`^(!?)(merged|stale)$`)
+	numberPattern  = regexp.MustCompile(`^(age|ahead|behind|stashes)(<=|>=|=|<|>)(\S+)$`)
+	matchPattern   = regexp.MustCompile(`^(name|author)=(.+)$`)
+	durationSuffix = map[byte]time.Duration{'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+)
+
+// ParseRule parses a rule such as `!merged age>180d -> archive`. Conditions
+// are merged, stale, their negation with !, comparisons of age, ahead,
+// behind and stashes, and glob matches of name and author, where
+// `author=me` is the current user.
+func ParseRule(text string) (*Rule, error) {
+	parts := strings.SplitN(text, "->", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("rule '%s': expected <conditions> -> <action>", text)
+	}
+
+	r := &Rule{Text: strings.TrimSpace(text), Action: strings.TrimSpace(parts[1])}
+
+	switch r.Action {
+	case ActionKeep, ActionDelete, ActionArchive:
+	default:
+		return nil, fmt.Errorf("rule '%s': unknown action '%s', expected keep, delete or archive", text, r.Action)
+	}
+
+	for _, term := range strings.Fields(parts[0]) {
+		cond, err := parseCondition(term)
+		if err != nil {
+			return nil, fmt.Errorf("rule '%s': %w", text, err)
+		}
+		r.conditions = append(r.conditions, cond)
+	}
+
+	return r, nil
+}
+
+func parseCondition(term string) (condition, error) {
+	if match := flagPattern.FindStringSubmatch(term); match != nil {
+		negate := match[1] == "!"
+		flag := match[2]
+		return func(c *Comparison, ctx GCContext) bool {
+			value := c.IsMerged
+			if flag == "stale" {
+				value = c.When().Before(ctx.Now.Add(-StaleAfter))
+			}
+			return value != negate
+		}, nil
+	}
+
+	if match := numberPattern.FindStringSubmatch(term); match != nil {
+		field, op := match[1], match[2]
+
+		if field == "age" {
+			limit, err := parseAge(match[3])
+			if err != nil {
+				return nil, err
+			}
+			return func(c *Comparison, ctx GCContext) bool {
+				return compareInts(int64(ctx.Now.Sub(c.When())), op, int64(limit))
+			}, nil
+		}
+
+		limit, err := strconv.Atoi(match[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid number in '%s'", term)
+		}
+		return func(c *Comparison, ctx GCContext) bool {
+			value := c.Ahead
+			switch field {
+			case "behind":
+				value = c.Behind
+			case "stashes":
+				value = c.Stashes
+			}
+			return compareInts(int64(value), op, int64(limit))
+		}, nil
+	}
+
+	if match := matchPattern.FindStringSubmatch(term); match != nil {
+		field, pattern := match[1], match[2]
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern in '%s'", term)
+		}
+
+		if field == "name" {
+			return func(c *Comparison, ctx GCContext) bool {
+				matched, _ := path.Match(pattern, c.Name())
+				return matched
+			}, nil
+		}
+
+		return func(c *Comparison, ctx GCContext) bool {
+			if c.Commit() == nil {
+				return false
+			}
+			author := c.Commit().Author()
+
+			patterns := []string{pattern}
+			if pattern == "me" {
+				patterns = ctx.Me
+			}
+			for _, p := range patterns {
+				if matched, _ := path.Match(p, author.Name); matched {
+					return true
+				}
+				if matched, _ := path.Match(p, author.Email); matched {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown condition '%s'", term)
+}
+
+// parseAge parses a duration in hours, days or weeks: 12h, 14d, 2w.
+func parseAge(s string) (time.Duration, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid age '%s', expected a number of h, d or w", s)
+	}
+
+	unit, ok := durationSuffix[s[len(s)-1]]
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if !ok || err != nil {
+		return 0, fmt.Errorf("invalid age '%s', expected a number of h, d or w", s)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+func compareInts(a int64, op string, b int64) bool {
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return a == b
+}
+
+// Matches reports whether every condition of the rule holds.
+func (r *Rule) Matches(c *Comparison, ctx GCContext) bool {
+	for _, cond := range r.conditions {
+		if !cond(c, ctx) {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadRules parses the rules of GCRuleKey in order.
+func LoadRules(repo *git.Repository) ([]*Rule, error) {
+	config, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []*Rule{}
+
+	iterator, err := config.NewMultivarIterator(GCRuleKey, "")
+	if err != nil {
+		return rules, nil
+	}
+	defer iterator.Free()
+
+	for {
+		entry, err := iterator.Next()
+		if git.IsErrorCode(err, git.ErrorCodeIterOver) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", GCRuleKey, err)
+		}
+
+		rule, err := ParseRule(entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		rule.Index = len(rules) + 1
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// NewGCContext evaluates rules now, as the user of the repository config.
+func NewGCContext(repo *git.Repository) GCContext {
+	ctx := GCContext{Now: time.Now()}
+
+	if config, err := repo.Config(); err == nil {
+		for _, key := range []string{"user.name", "user.email"} {
+			if value, err := config.LookupString(key); err == nil && value != "" {
+				ctx.Me = append(ctx.Me, value)
+			}
+		}
+	}
+	return ctx
+}
+
+// GCStep is what gc does with a branch, and why. Rule is nil when no rule
+// matched or the branch is protected.
+type GCStep struct {
+	*Comparison
+	Action string
+	Rule   *Rule
+
+	// Protected branches are kept whatever the rules say.
+	Protected bool
+}
+
+// Reason describes why the action was chosen.
+func (s GCStep) Reason() string {
+	switch {
+	case s.Protected:
+		return "protected"
+	case s.Rule == nil:
+		return "no rule matched"
+	}
+	return fmt.Sprintf("rule %d: %s", s.Rule.Index, s.Rule.Text)
+}
+
+// PlanGC picks the action of the first matching rule for every branch. Branches
+// that match no rule are kept, as are protected ones and the ones that could
+// not be compared.
+func PlanGC(comparisons Comparisons, rules []*Rule, protected func(string) bool, ctx GCContext) []GCStep {
+	plan := []GCStep{}
+
+	for _, comp := range comparisons {
+		if comp.Err != nil {
+			continue
+		}
+
+		step := GCStep{Comparison: comp, Action: ActionKeep}
+
+		if protected(comp.Name()) {
+			step.Protected = true
+		} else {
+			for _, rule := range rules {
+				if rule.Matches(comp, ctx) {
+					step.Action = rule.Action
+					step.Rule = rule
+					break
+				}
+			}
+		}
+
+		plan = append(plan, step)
+	}
+
+	return plan
+}
+
+// GCJournalPath returns the location of the gc journal of the repository.
+func GCJournalPath(repo *git.Repository) string {
+	return filepath.Join(repo.Path(), GCJournalFile)
+}
+
+// Apply deletes or archives the branch of the step and appends a line to the
+// journal: time, action, branch, tip, archive ref and reason, separated by
+// tabs. Kept branches are left alone.
+func (s GCStep) Apply(repo *git.Repository, journalPath string, now time.Time) error {
+	if s.Action == ActionKeep {
+		return nil
+	}
+
+	tip := s.Oid.String()
+	archived := "-"
+
+	switch s.Action {
+	case ActionDelete:
+		if err := s.Branch.Delete(); err != nil {
+			return fmt.Errorf("could not delete '%s': %w", s.Name(), err)
+		}
+	case ActionArchive:
+		ref, err := ArchiveBranch(repo, s.Branch, false)
+		if err != nil {
+			return err
+		}
+		archived = ref
+	}
+
+	journal, err := os.OpenFile(journalPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open the gc journal: %w", err)
+	}
+	defer journal.Close()
+
+	_, err = fmt.Fprintf(journal, "%s\t%s\t%s\t%s\t%s\t%s\n",
+		now.UTC().Format(time.RFC3339), s.Action, s.Name(), tip, archived, s.Reason())
+	return err
+}
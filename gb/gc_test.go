@@ -0,0 +1,134 @@
+package gb
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+func TestParseRule(t *testing.T) {
+	for _, text := range []string{
+		"merged age>14d -> delete",
+		"!merged age>=180d -> archive",
+		"name=release/* -> keep",
+		"author=me ahead=0 -> delete",
+		"stashes=0 behind>100 -> delete",
+	} {
+		if _, err := ParseRule(text); err != nil {
+			t.Errorf("%s: %s", text, err)
+		}
+	}
+
+	for _, text := range []string{
+		"merged",
+		"merged -> drop",
+		"age>14 -> delete",
+		"color=red -> delete",
+	} {
+		if _, err := ParseRule(text); err == nil {
+			t.Errorf("%s: expected an error", text)
+		}
+	}
+}
+
+func TestGC(t *testing.T) {
+	f := newListFixture(t)
+	f.branch("release/1", mustTarget(t, f, "merged"))
+
+	for _, rule := range []string{
+		"name=release/* -> keep",
+		"merged age>14d -> delete",
+		"!merged age>180d -> archive",
+	} {
+		f.config().SetMultivar(GCRuleKey, "^a^", rule)
+	}
+
+	rules, err := LoadRules(f.repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 || rules[1].Index != 2 {
+		t.Fatalf("got %d rules", len(rules))
+	}
+
+	opts := f.options()
+	opts.Flat = true
+	comparisons, err := Compare(context.Background(), f.repo, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protection, err := NewProtection(f.repo, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan := func(now time.Time) map[string]GCStep {
+		steps := make(map[string]GCStep)
+		for _, step := range PlanGC(comparisons, rules, protection.Protected, GCContext{Now: now}) {
+			steps[step.Name()] = step
+		}
+		return steps
+	}
+
+	steps := plan(time.Now().Add(30 * 24 * time.Hour))
+	for name, want := range map[string]string{
+		"main":      ActionKeep,
+		"release/1": ActionKeep,
+		"merged":    ActionDelete,
+		"feature":   ActionKeep,
+	} {
+		if steps[name].Action != want {
+			t.Errorf("%s: got %s (%s), want %s", name, steps[name].Action, steps[name].Reason(), want)
+		}
+	}
+	if reason := steps["merged"].Reason(); reason != "rule 2: merged age>14d -> delete" {
+		t.Errorf("merged: got reason %q", reason)
+	}
+	if !steps["main"].Protected {
+		t.Error("main should be protected")
+	}
+
+	steps = plan(time.Now().Add(200 * 24 * time.Hour))
+	if steps["feature"].Action != ActionArchive {
+		t.Fatalf("feature: got %s, want archive", steps["feature"].Action)
+	}
+
+	journal := GCJournalPath(f.repo)
+	now := time.Now()
+	for _, name := range []string{"merged", "feature", "release/1"} {
+		if err := steps[name].Apply(f.repo, journal, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := f.repo.LookupBranch("merged", git.BranchLocal); err == nil {
+		t.Error("merged was not deleted")
+	}
+	if _, err := f.repo.LookupBranch("feature", git.BranchLocal); err == nil {
+		t.Error("feature was not archived")
+	}
+	if _, err := f.repo.References.Lookup(ArchivePrefix + "feature"); err != nil {
+		t.Error("feature has no archive ref")
+	}
+	if _, err := f.repo.LookupBranch("release/1", git.BranchLocal); err != nil {
+		t.Error("release/1 should be kept")
+	}
+
+	bits, err := ioutil.ReadFile(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(bits)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "\tdelete\tmerged\t") || !strings.Contains(lines[1], "\tarchive\tfeature\t") {
+		t.Errorf("journal:\n%s", bits)
+	}
+	if filepath.Dir(journal) != filepath.Clean(f.repo.Path()) {
+		t.Errorf("journal outside the git directory: %s", journal)
+	}
+}
@@ -0,0 +1,151 @@
+package gb
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GitHubURL is the API of github.com.
+const GitHubURL = "https://api.github.com"
+
+// GitHub looks up pull requests through the GitHub REST API.
+type GitHub struct {
+	BaseURL    string
+	Repository string
+	Token      string
+	Client     *http.Client
+}
+
+// NewGitHub returns a provider for the "owner/name" repository. An empty
+// baseURL uses GitHubURL.
+func NewGitHub(baseURL, repository, token string) *GitHub {
+	if baseURL == "" {
+		baseURL = GitHubURL
+	}
+
+	return &GitHub{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Repository: repository,
+		Token:      token,
+		Client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (g *GitHub) Name() string {
+	return "github " + g.BaseURL + " " + g.Repository
+}
+
+func (g *GitHub) get(path string, query url.Values, v interface{}) error {
+	u := g.BaseURL + "/repos/" + g.Repository + path
+	if query != nil {
+		u += "?" + query.Encode()
+	}
+
+	headers := map[string]string{"Accept": "application/vnd.github+json"}
+	if g.Token != "" {
+		headers["Authorization"] = "Bearer " + g.Token
+	}
+	return getJSON(g.Client, u, headers, v)
+}
+
+type githubPull struct {
+	Number   int     `json:"number"`
+	State    string  `json:"state"`
+	Draft    bool    `json:"draft"`
+	MergedAt *string `json:"merged_at"`
+	HTMLURL  string  `json:"html_url"`
+}
+
+type githubReview struct {
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	State string `json:"state"`
+}
+
+func (g *GitHub) PullRequest(branch string) (*PullRequest, error) {
+	owner := strings.SplitN(g.Repository, "/", 2)[0]
+
+	pulls := []githubPull{}
+	query := url.Values{"head": {owner + ":" + branch}, "state": {"all"}, "per_page": {"1"}}
+	if err := g.get("/pulls", query, &pulls); err != nil {
+		return nil, err
+	}
+
+	if len(pulls) == 0 {
+		return nil, nil
+	}
+	pull := pulls[0]
+
+	pr := &PullRequest{Number: pull.Number, URL: pull.HTMLURL}
+	switch {
+	case pull.MergedAt != nil:
+		pr.State = PullRequestMerged
+	case pull.State == "closed":
+		pr.State = PullRequestClosed
+	case pull.Draft:
+		pr.State = PullRequestDraft
+	default:
+		pr.State = PullRequestOpen
+	}
+
+	if pr.State == PullRequestOpen || pr.State == PullRequestDraft {
+		reviews := []githubReview{}
+		if err := g.get(fmt.Sprintf("/pulls/%d/reviews", pull.Number), url.Values{"per_page": {"100"}}, &reviews); err != nil {
+			return nil, err
+		}
+		pr.Review = githubReviewStatus(reviews)
+	}
+
+	return pr, nil
+}
+
+// githubReviewStatus reduces the reviews, oldest first, to the latest verdict
+// of each reviewer: any request for changes wins over approvals.
+func githubReviewStatus(reviews []githubReview) string {
+	latest := make(map[string]string)
+	for _, review := range reviews {
+		switch review.State {
+		case "APPROVED", "CHANGES_REQUESTED", "DISMISSED":
+			latest[review.User.Login] = review.State
+		}
+	}
+
+	status := ReviewRequired
+	for _, state := range latest {
+		if state == "CHANGES_REQUESTED" {
+			return ReviewChangesRequested
+		}
+		if state == "APPROVED" {
+			status = ReviewApproved
+		}
+	}
+	return status
+}
+
+type githubCombinedStatus struct {
+	State      string `json:"state"`
+	TotalCount int    `json:"total_count"`
+}
+
+func (g *GitHub) CommitStatus(oid string) (string, error) {
+	combined := githubCombinedStatus{}
+	if err := g.get("/commits/"+oid+"/status", nil, &combined); err != nil {
+		return "", err
+	}
+
+	if combined.TotalCount == 0 {
+		return "", nil
+	}
+
+	switch combined.State {
+	case "success":
+		return CISuccess, nil
+	case "failure", "error":
+		return CIFailure, nil
+	}
+	return CIPending, nil
+}
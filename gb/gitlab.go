@@ -0,0 +1,128 @@
+package gb
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GitLabURL is the API of gitlab.com.
+const GitLabURL = "https://gitlab.com/api/v4"
+
+// GitLab looks up merge requests through the GitLab REST API.
+type GitLab struct {
+	BaseURL    string
+	Repository string
+	Token      string
+	Client     *http.Client
+}
+
+// NewGitLab returns a provider for the "group/project" repository. An empty
+// baseURL uses GitLabURL.
+func NewGitLab(baseURL, repository, token string) *GitLab {
+	if baseURL == "" {
+		baseURL = GitLabURL
+	}
+
+	return &GitLab{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Repository: repository,
+		Token:      token,
+		Client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (g *GitLab) Name() string {
+	return "gitlab " + g.BaseURL + " " + g.Repository
+}
+
+func (g *GitLab) get(path string, query url.Values, v interface{}) error {
+	u := g.BaseURL + "/projects/" + url.PathEscape(g.Repository) + path
+	if query != nil {
+		u += "?" + query.Encode()
+	}
+
+	return getJSON(g.Client, u, map[string]string{"PRIVATE-TOKEN": g.Token}, v)
+}
+
+type gitlabMergeRequest struct {
+	IID            int    `json:"iid"`
+	State          string `json:"state"`
+	Draft          bool   `json:"draft"`
+	WorkInProgress bool   `json:"work_in_progress"`
+	WebURL         string `json:"web_url"`
+}
+
+type gitlabApprovals struct {
+	Approved bool `json:"approved"`
+}
+
+func (g *GitLab) PullRequest(branch string) (*PullRequest, error) {
+	requests := []gitlabMergeRequest{}
+	query := url.Values{"source_branch": {branch}, "state": {"all"}, "order_by": {"created_at"}, "per_page": {"1"}}
+	if err := g.get("/merge_requests", query, &requests); err != nil {
+		return nil, err
+	}
+
+	if len(requests) == 0 {
+		return nil, nil
+	}
+	request := requests[0]
+
+	pr := &PullRequest{Number: request.IID, URL: request.WebURL}
+	switch {
+	case request.State == "merged":
+		pr.State = PullRequestMerged
+	case request.State == "closed" || request.State == "locked":
+		pr.State = PullRequestClosed
+	case request.Draft || request.WorkInProgress:
+		pr.State = PullRequestDraft
+	default:
+		pr.State = PullRequestOpen
+	}
+
+	if pr.State == PullRequestOpen || pr.State == PullRequestDraft {
+		approvals := gitlabApprovals{}
+		if err := g.get(fmt.Sprintf("/merge_requests/%d/approvals", request.IID), nil, &approvals); err != nil {
+			return nil, err
+		}
+
+		pr.Review = ReviewRequired
+		if approvals.Approved {
+			pr.Review = ReviewApproved
+		}
+	}
+
+	return pr, nil
+}
+
+type gitlabCommit struct {
+	LastPipeline *struct {
+		Status string `json:"status"`
+	} `json:"last_pipeline"`
+}
+
+// CommitStatus returns the status of the last pipeline of the commit.
+// Canceled and skipped pipelines have no status.
+func (g *GitLab) CommitStatus(oid string) (string, error) {
+	commit := gitlabCommit{}
+	if err := g.get("/repository/commits/"+oid, nil, &commit); err != nil {
+		return "", err
+	}
+
+	if commit.LastPipeline == nil {
+		return "", nil
+	}
+
+	switch commit.LastPipeline.Status {
+	case "success":
+		return CISuccess, nil
+	case "failed":
+		return CIFailure, nil
+	case "canceled", "skipped":
+		return "", nil
+	}
+	return CIPending, nil
+}
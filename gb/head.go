@@ -0,0 +1,240 @@
+package gb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// Operations in progress, from repo.State().
+const (
+	OperationRebase     = "rebasing"
+	OperationMerge      = "merging"
+	OperationCherryPick = "cherry-picking"
+	OperationRevert     = "reverting"
+	OperationBisect     = "bisecting"
+	OperationApply      = "applying patches"
+)
+
+// HeadState is what HEAD is doing: detached, and the operation in progress.
+type HeadState struct {
+	Detached bool
+	Oid      *git.Oid
+
+	// Operation is one of the Operation constants, or empty.
+	Operation string
+
+	// Branch is the branch being rebased, or the one HEAD is on otherwise.
+	Branch string
+
+	// Other is the rebase target, or the commit being merged, cherry-picked
+	// or reverted, named after a branch pointing at it when there is one.
+	Other string
+
+	// Step and Total are the progress of a rebase or patch series.
+	Step  int
+	Total int
+}
+
+// ReadHeadState reads the state of HEAD and the operation in progress from
+// the git directory. Commits are named after the base when it points at them.
+func ReadHeadState(repo *git.Repository, baseBranch string) (*HeadState, error) {
+	s := new(HeadState)
+
+	detached, err := repo.IsHeadDetached()
+	if err != nil {
+		return nil, fmt.Errorf("could not read HEAD: %w", err)
+	}
+	s.Detached = detached
+
+	head, err := repo.Head()
+	if git.IsErrorCode(err, git.ErrorCodeUnbornBranch) {
+		// A new repository or orphan branch has no commit to describe.
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read HEAD: %w", err)
+	}
+	s.Oid = head.Target()
+	if !detached {
+		s.Branch = head.Shorthand()
+	}
+
+	dir := repo.Path()
+
+	switch repo.State() {
+	case git.RepositoryStateRebase, git.RepositoryStateRebaseInteractive, git.RepositoryStateRebaseMerge:
+		s.Operation = OperationRebase
+		s.readRebase(repo, baseBranch, filepath.Join(dir, "rebase-merge"), "msgnum", "end")
+	case git.RepositoryStateApplyMailbox, git.RepositoryStateApplyMailboxOrRebase:
+		// git am leaves an empty `applying` file, git rebase a `rebasing` one.
+		s.Operation = OperationRebase
+		if _, err := os.Stat(filepath.Join(dir, "rebase-apply", "applying")); err == nil {
+			s.Operation = OperationApply
+		}
+		s.readRebase(repo, baseBranch, filepath.Join(dir, "rebase-apply"), "next", "last")
+	case git.RepositoryStateMerge:
+		s.Operation = OperationMerge
+		s.Other = commitName(repo, baseBranch, FirstLine(readTrimmed(filepath.Join(dir, "MERGE_HEAD"))))
+	case git.RepositoryStateCherrypick:
+		s.Operation = OperationCherryPick
+		s.Other = commitName(repo, baseBranch, readTrimmed(filepath.Join(dir, "CHERRY_PICK_HEAD")))
+	case git.RepositoryStateRevert:
+		s.Operation = OperationRevert
+		s.Other = commitName(repo, baseBranch, readTrimmed(filepath.Join(dir, "REVERT_HEAD")))
+	case git.RepositoryStateBisect:
+		s.Operation = OperationBisect
+		if start := readTrimmed(filepath.Join(dir, "BISECT_START")); start != "" && s.Branch == "" {
+			s.Branch = start
+		}
+	}
+
+	return s, nil
+}
+
+// readRebase reads the branch, target and progress of a rebase from its state
+// directory.
+func (s *HeadState) readRebase(repo *git.Repository, baseBranch string, dir string, step string, total string) {
+	if name := rebaseHeadName(filepath.Dir(dir)); name != "" {
+		s.Branch = strings.TrimPrefix(name, "refs/heads/")
+	}
+	s.Other = commitName(repo, baseBranch, readTrimmed(filepath.Join(dir, "onto")))
+	s.Step, _ = strconv.Atoi(readTrimmed(filepath.Join(dir, step)))
+	s.Total, _ = strconv.Atoi(readTrimmed(filepath.Join(dir, total)))
+}
+
+// rebaseHeadName returns the ref of the branch being rebased in the git
+// directory, which stays checked out while HEAD is detached, or "".
+func rebaseHeadName(gitdir string) string {
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		name := readTrimmed(filepath.Join(gitdir, dir, "head-name"))
+		if strings.HasPrefix(name, "refs/") {
+			return name
+		}
+	}
+	return ""
+}
+
+// commitName names the commit after the base or a local branch pointing at
+// it, or shortens the oid.
+func commitName(repo *git.Repository, baseBranch string, oid string) string {
+	if oid == "" {
+		return ""
+	}
+
+	if base_oid, err := LookupBaseOid(repo, baseBranch); err == nil && base_oid.String() == oid {
+		return baseBranch
+	}
+
+	name := ""
+	branch_iterator, err := repo.NewBranchIterator(git.BranchLocal)
+	if err == nil {
+		branch_iterator.ForEach(func(branch *git.Branch, _ git.BranchType) error {
+			if name == "" && branch.Target() != nil && branch.Target().String() == oid {
+				name, _ = branch.Name()
+			}
+			return nil
+		})
+		branch_iterator.Free()
+	}
+	if name != "" {
+		return name
+	}
+
+	if len(oid) > 7 {
+		return oid[:7]
+	}
+	return oid
+}
+
+// InProgress reports whether there is anything worth a header.
+func (s *HeadState) InProgress() bool {
+	return s.Detached || s.Operation != ""
+}
+
+// String describes the state, such as "rebasing feature-x onto main, 3/7" or
+// "HEAD detached at 1a2b3c4".
+func (s *HeadState) String() string {
+	var b strings.Builder
+
+	switch s.Operation {
+	case "":
+		if s.Detached && s.Oid != nil {
+			fmt.Fprintf(&b, "HEAD detached at %s", s.Oid.String()[:7])
+		}
+		return b.String()
+	case OperationRebase:
+		b.WriteString(s.Operation)
+		if s.Branch != "" {
+			b.WriteString(" " + s.Branch)
+		}
+		if s.Other != "" {
+			b.WriteString(" onto " + s.Other)
+		}
+	case OperationMerge:
+		b.WriteString(s.Operation)
+		if s.Other != "" {
+			b.WriteString(" " + s.Other)
+		}
+		if s.Branch != "" {
+			b.WriteString(" into " + s.Branch)
+		}
+	case OperationBisect:
+		b.WriteString(s.Operation)
+		if s.Branch != "" {
+			b.WriteString(", started on " + s.Branch)
+		}
+	default:
+		b.WriteString(s.Operation)
+		if s.Other != "" {
+			b.WriteString(" " + s.Other)
+		}
+		if s.Branch != "" {
+			b.WriteString(" on " + s.Branch)
+		}
+	}
+
+	if s.Total > 0 {
+		fmt.Fprintf(&b, ", %d/%d", s.Step, s.Total)
+	}
+
+	return b.String()
+}
+
+// DetachedStatus compares the detached HEAD against the base, as a pseudo
+// branch named after its oid. It returns nil when HEAD is on a branch.
+func (s *HeadState) DetachedStatus(repo *git.Repository, baseBranch string) (*BranchStatus, error) {
+	if !s.Detached || s.Oid == nil {
+		return nil, nil
+	}
+
+	base_oid, err := LookupBaseOid(repo, baseBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := repo.LookupCommit(s.Oid)
+	if err != nil {
+		return nil, fmt.Errorf("could not lookup commit '%s': %w", s.Oid.String(), err)
+	}
+
+	status := &BranchStatus{
+		Name:   fmt.Sprintf("(HEAD detached at %s)", s.Oid.String()[:7]),
+		Oid:    s.Oid.String(),
+		When:   commit.Committer().When,
+		Author: commit.Author().Name,
+		IsHead: true,
+	}
+
+	status.Ahead, status.Behind, err = repo.AheadBehind(s.Oid, base_oid)
+	if err != nil {
+		return nil, fmt.Errorf("error getting ahead/behind of '%s' against '%s': %w", s.Oid.String(), base_oid.String(), err)
+	}
+	status.IsMerged = status.Ahead == 0
+
+	return status, nil
+}
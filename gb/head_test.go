@@ -0,0 +1,112 @@
+package gb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+func TestDetachedHead(t *testing.T) {
+	f := newListFixture(t)
+
+	feature, err := f.repo.LookupBranch("feature", git.BranchLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.repo.SetHeadDetached(feature.Target()); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := ReadHeadState(f.repo, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.Detached || state.Operation != "" {
+		t.Fatalf("got %+v, want detached without operation", state)
+	}
+	if want := "HEAD detached at " + feature.Target().String()[:7]; state.String() != want {
+		t.Errorf("got %q, want %q", state, want)
+	}
+
+	status, err := state.DetachedStatus(f.repo, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.IsHead || status.Ahead != 2 || status.Behind != 1 {
+		t.Errorf("got head=%v ahead=%d behind=%d, want true 2 1", status.IsHead, status.Ahead, status.Behind)
+	}
+}
+
+func TestOrphanHead(t *testing.T) {
+	f := newListFixture(t)
+
+	// Like `git checkout --orphan`: HEAD points to a branch without commits.
+	if err := f.repo.SetHead("refs/heads/orphan"); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := ReadHeadState(f.repo, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.InProgress() {
+		t.Errorf("got %q, want nothing in progress", state)
+	}
+
+	if status, err := state.DetachedStatus(f.repo, "main"); err != nil || status != nil {
+		t.Errorf("got %+v, %v, want no detached row", status, err)
+	}
+}
+
+func TestRebaseInProgress(t *testing.T) {
+	f := newListFixture(t)
+
+	main, err := f.repo.LookupBranch("main", git.BranchLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.repo.SetHeadDetached(main.Target()); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(f.repo.Path(), "rebase-merge")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"head-name": "refs/heads/feature\n",
+		"onto":      main.Target().String() + "\n",
+		"msgnum":    "3\n",
+		"end":       "7\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state, err := ReadHeadState(f.repo, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "rebasing feature onto main, 3/7"; state.String() != want {
+		t.Errorf("got %q, want %q", state, want)
+	}
+
+	if _, ok := CheckedOut(f.repo)["refs/heads/feature"]; !ok {
+		t.Error("the branch being rebased should count as checked out")
+	}
+
+	statuses, err := List(context.Background(), f.repo, f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.IsHead != (status.Name == "feature") {
+			t.Errorf("%s: IsHead=%v", status.Name, status.IsHead)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package gb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// Options selects the base branch and filters the branches returned by List.
+type Options struct {
+	// Base is the branch to compare against. BaseBranch picks one when empty.
+	Base string
+
+	// CachePath is where comparisons are cached. Defaults to CachePath(repo).
+	CachePath string
+
+	// Fetch is FetchBase to fetch the remote of the base branch, or FetchAll
+	// to fetch every remote, before comparing. Fetch progress is written to
+	// Progress when it isn't nil.
+	Fetch    string
+	Progress io.Writer
+
+	// Ahead and Behind only keep branches with exactly that many commits
+	// ahead or behind the base. -1 keeps every branch.
+	Ahead  int
+	Behind int
+
+	Merged   bool
+	NoMerged bool
+
+	// Stale only keeps branches without commits for StaleAfter.
+	Stale bool
+
+	// Pattern only keeps branches whose name or description matches.
+	Pattern *regexp.Regexp
+
+	// Conflicts predicts whether each unmerged branch merges cleanly.
+	Conflicts bool
+
+	// Flat disables stacked branch detection.
+	Flat bool
+
+	// Provider is the hosting provider used by PullRequests and CI. Nothing
+	// is looked up on the network unless one of them is set.
+	Provider Provider
+
+	// PullRequests looks up the pull request of every branch. Merged pull
+	// requests make their branch count as merged.
+	PullRequests bool
+
+	// CI looks up the combined commit status of every branch tip, and
+	// CIFailing only keeps the branches whose status is a failure.
+	CI        bool
+	CIFailing bool
+
+	// Worktrees counts the stashes of every branch and the uncommitted
+	// changes of the branches checked out in a worktree.
+	Worktrees bool
+
+	// RecordStacks saves the detected stack parents in the git config so
+	// stacks survive a rewritten parent branch. A config that can't be
+	// written doesn't fail the comparison.
+	RecordStacks bool
+}
+
+// DefaultOptions keeps every branch.
+func DefaultOptions() Options {
+	return Options{Ahead: -1, Behind: -1}
+}
+
+// BranchStatus is the result of comparing one branch against the base.
+type BranchStatus struct {
+	Name        string
+	Oid         string
+	When        time.Time
+	Author      string
+	Description string
+
+	IsHead   bool
+	IsBase   bool
+	IsMerged bool
+	IsStale  bool
+	Ahead    int
+	Behind   int
+
+	// Parent is the branch this one is stacked on, if any, and Depth its
+	// nesting level in the stack.
+	Parent       string
+	ParentAhead  int
+	ParentBehind int
+	Depth        int
+
+	ConflictsChecked bool
+	Conflicts        []string
+
+	PullRequest *PullRequest
+	CI          string
+
+	// Dirty is nil unless the branch is checked out in a worktree.
+	Dirty   *Dirtiness
+	Stashes int
+
+	// Err is set when the branch could not be compared.
+	Err *BranchError
+}
+
+// Color is the color of the branch in every view: green for the current
+// branch, red once stale and yellow otherwise.
+func (s BranchStatus) Color() string {
+	if s.IsHead {
+		return "green"
+	} else if s.IsStale {
+		return "red"
+	} else {
+		return "yellow"
+	}
+}
+
+// TreeName is the branch name indented under its parent.
+func (s BranchStatus) TreeName() string {
+	if s.Depth == 0 {
+		return s.Name
+	}
+	return strings.Repeat("  ", s.Depth-1) + "└ " + s.Name
+}
+
+// RelativeAheadBehind returns the ahead/behind counts against the parent
+// branch when the branch is stacked, or against the base otherwise.
+func (s BranchStatus) RelativeAheadBehind() (int, int) {
+	if s.Parent != "" {
+		return s.ParentAhead, s.ParentBehind
+	}
+	return s.Ahead, s.Behind
+}
+
+// Keep reports whether the comparison passes the filters of the options. The
+// base branch and branches that could not be compared are always kept.
+func (opts Options) Keep(c *Comparison, baseBranch string) bool {
+	if c.IsBase(baseBranch) || c.Err != nil {
+		return true
+	}
+
+	if opts.Ahead != -1 && opts.Ahead != c.Ahead {
+		return false
+	}
+
+	if opts.Behind != -1 && opts.Behind != c.Behind {
+		return false
+	}
+
+	if opts.Merged && !c.IsMerged {
+		return false
+	}
+
+	if opts.NoMerged && c.IsMerged {
+		return false
+	}
+
+	if opts.Stale && !c.IsStale() {
+		return false
+	}
+
+	if opts.CIFailing && c.CI != CIFailure {
+		return false
+	}
+
+	if opts.Pattern != nil && !c.Matches(opts.Pattern) {
+		return false
+	}
+
+	return true
+}
+
+// Status copies the comparison into a BranchStatus.
+func (c *Comparison) Status(baseBranch string) BranchStatus {
+	s := BranchStatus{
+		Name:        c.Name(),
+		When:        c.When(),
+		Description: c.Description(),
+
+		IsHead:   c.IsHead(),
+		IsBase:   c.IsBase(baseBranch),
+		IsMerged: c.IsMerged,
+		IsStale:  c.IsStale(),
+		Ahead:    c.Ahead,
+		Behind:   c.Behind,
+
+		Depth: c.Depth,
+
+		ConflictsChecked: c.ConflictsChecked,
+		Conflicts:        c.Conflicts,
+
+		PullRequest: c.PullRequest,
+		CI:          c.CI,
+		Dirty:       c.Dirty,
+		Stashes:     c.Stashes,
+		Err:         c.Err,
+	}
+
+	if c.Oid != nil {
+		s.Oid = c.Oid.String()
+	}
+
+	if c.Commit() != nil {
+		s.Author = c.Commit().Author().Name
+	}
+
+	if c.Parent != nil {
+		s.Parent = c.Parent.Name()
+		s.ParentAhead = c.ParentAhead
+		s.ParentBehind = c.ParentBehind
+	}
+
+	return s
+}
+
+// Compare executes the comparison of every local branch against the base,
+// reading and updating the cache. Unless opts.Flat is set, stacked branches
+// are detected and the result is ordered as a tree.
+//
+// Branches that can't be compared are returned with Err set, along with a
+// *PartialError.
+func Compare(ctx context.Context, repo *git.Repository, opts Options) (Comparisons, error) {
+	baseBranch := BaseBranch(repo, opts.Base)
+
+	remotes, err := FetchRemotes(repo, opts.Fetch, baseBranch)
+	if err != nil {
+		return nil, err
+	}
+	if err := Fetch(repo, remotes, opts.Progress); err != nil {
+		return nil, err
+	}
+
+	base_oid, err := LookupBaseOid(repo, baseBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	cachePath := opts.CachePath
+	if cachePath == "" {
+		cachePath = CachePath(repo)
+	}
+	store := NewCacheStore(cachePath)
+
+	comparisons, err := LocalComparisons(repo, base_oid, store)
+	if err != nil {
+		return nil, err
+	}
+	comparisons.resolveBase(repo, baseBranch)
+
+	// HEAD is detached during a rebase, but the branch is still the current one.
+	if rebasing := rebaseHeadName(repo.Path()); rebasing != "" {
+		for _, comp := range comparisons {
+			if comp.Branch.Reference.Name() == rebasing {
+				comp.isHead = true
+			}
+		}
+	}
+
+	for _, comp := range comparisons {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if comp.Execute() == nil {
+			store.Add(comp)
+		}
+	}
+
+	if opts.Worktrees {
+		if err := comparisons.SetWorktreeStatus(repo); err != nil {
+			return nil, err
+		}
+	}
+
+	if !opts.Flat {
+		comparisons.DetectStacks(baseBranch)
+
+		if opts.RecordStacks {
+			comparisons.RecordStacks(repo)
+		}
+
+		comparisons = comparisons.Stacked()
+	}
+
+	if opts.Provider != nil && (opts.PullRequests || opts.CI) {
+		providerCachePath := ProviderCachePath(repo)
+		providerCache := NewProviderCache(providerCachePath)
+
+		if opts.PullRequests {
+			comparisons.SetPullRequests(opts.Provider, providerCache, baseBranch)
+		}
+
+		if opts.CI {
+			comparisons.SetCommitStatuses(opts.Provider, providerCache)
+		}
+
+		providerCache.WriteToFile(providerCachePath)
+	}
+
+	if opts.Conflicts {
+		for _, comp := range comparisons {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			if !opts.Keep(comp, baseBranch) || comp.IsBase(baseBranch) {
+				continue
+			}
+
+			if comp.SetConflicts() == nil {
+				store.AddConflicts(comp)
+			}
+		}
+	}
+
+	// The cache only saves time: a repository it can't be written to is
+	// still listed.
+	store.WriteToFile(cachePath)
+
+	return comparisons, comparisons.Errors()
+}
+
+// CompareRemote executes the comparison of every remote-tracking branch of the
+// remote, or of every remote when remoteName is empty, against the base,
+// reading and updating the cache. Only opts.Base, opts.CachePath, opts.Fetch
+// and opts.Progress are used.
+func CompareRemote(ctx context.Context, repo *git.Repository, remoteName string, opts Options) (Comparisons, error) {
+	baseBranch := BaseBranch(repo, opts.Base)
+
+	remotes, err := FetchRemotes(repo, opts.Fetch, baseBranch)
+	if err != nil {
+		return nil, err
+	}
+	if err := Fetch(repo, remotes, opts.Progress); err != nil {
+		return nil, err
+	}
+
+	base_oid, err := LookupBaseOid(repo, baseBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	cachePath := opts.CachePath
+	if cachePath == "" {
+		cachePath = CachePath(repo)
+	}
+	store := NewCacheStore(cachePath)
+
+	comparisons, err := RemoteComparisons(repo, remoteName, base_oid, store)
+	if err != nil {
+		return nil, err
+	}
+	comparisons.resolveBase(repo, baseBranch)
+
+	for _, comp := range comparisons {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if comp.Execute() == nil {
+			store.Add(comp)
+		}
+	}
+
+	store.WriteToFile(cachePath)
+
+	return comparisons, comparisons.Errors()
+}
+
+// List compares every local branch against the base and returns the status of
+// the branches that pass the filters of the options, oldest first. When some
+// branches could not be compared, their status has Err set and a
+// *PartialError is returned along with every status.
+func List(ctx context.Context, repo *git.Repository, opts Options) ([]BranchStatus, error) {
+	comparisons, err := Compare(ctx, repo, opts)
+	var partial *PartialError
+	if err != nil && !errors.As(err, &partial) {
+		return nil, err
+	}
+
+	baseBranch := BaseBranch(repo, opts.Base)
+
+	statuses := []BranchStatus{}
+	for _, comp := range comparisons {
+		if !opts.Keep(comp, baseBranch) {
+			continue
+		}
+		statuses = append(statuses, comp.Status(baseBranch))
+	}
+
+	return statuses, err
+}
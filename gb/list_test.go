@@ -0,0 +1,213 @@
+package gb
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// newListFixture creates:
+//
+//	main:    c1 - c2 - c3
+//	merged:       c2
+//	feature:      c2 - f1 - f2
+func newListFixture(t *testing.T) *fixture {
+	f := newFixture(t)
+
+	c1 := f.commit(nil, "c1", map[string]string{"a.txt": "1"})
+	c2 := f.commit(c1, "c2", map[string]string{"a.txt": "2"})
+	f.branch("merged", c2)
+
+	f1 := f.commit(c2, "f1", map[string]string{"b.txt": "1"})
+	f2 := f.commit(f1, "f2", map[string]string{"b.txt": "2"})
+	f.branch("feature", f2)
+
+	c3 := f.commit(c2, "c3", map[string]string{"a.txt": "3"})
+	f.branch("main", c3)
+	f.checkout("main")
+
+	return f
+}
+
+func TestListAheadBehindMerged(t *testing.T) {
+	f := newListFixture(t)
+
+	statuses, err := List(context.Background(), f.repo, f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(statuses) != 3 {
+		t.Fatalf("got %d branches, want 3", len(statuses))
+	}
+
+	got := byName(statuses)
+
+	main := got["main"]
+	if !main.IsBase || !main.IsHead {
+		t.Errorf("main: IsBase=%v IsHead=%v, want both", main.IsBase, main.IsHead)
+	}
+
+	merged := got["merged"]
+	if !merged.IsMerged || merged.Ahead != 0 || merged.Behind != 1 {
+		t.Errorf("merged: IsMerged=%v ahead=%d behind=%d, want true 0 1", merged.IsMerged, merged.Ahead, merged.Behind)
+	}
+
+	feature := got["feature"]
+	if feature.IsMerged || feature.Ahead != 2 || feature.Behind != 1 {
+		t.Errorf("feature: IsMerged=%v ahead=%d behind=%d, want false 2 1", feature.IsMerged, feature.Ahead, feature.Behind)
+	}
+
+	// Sorted by the date of the last commit.
+	if statuses[0].Name != "merged" || statuses[2].Name != "main" {
+		t.Errorf("unexpected order: %s, %s, %s", statuses[0].Name, statuses[1].Name, statuses[2].Name)
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	f := newListFixture(t)
+
+	opts := f.options()
+	opts.NoMerged = true
+	statuses, err := List(context.Background(), f.repo, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := byName(statuses)["merged"]; ok {
+		t.Errorf("--no-merged kept the merged branch")
+	}
+	if _, ok := byName(statuses)["main"]; !ok {
+		t.Errorf("filters dropped the base branch")
+	}
+
+	opts = f.options()
+	opts.Pattern = regexp.MustCompile("(?i)FEAT")
+	statuses, err = List(context.Background(), f.repo, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 {
+		t.Errorf("pattern kept %d branches, want feature and main", len(statuses))
+	}
+
+	opts = f.options()
+	opts.Ahead = 2
+	statuses, err = List(context.Background(), f.repo, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := byName(statuses)["feature"]; !ok || len(statuses) != 2 {
+		t.Errorf("--ahead 2 did not keep only feature and main")
+	}
+
+	f.when = time.Now().Add(-2 * StaleAfter)
+	f.branch("old", f.commit(nil, "old", map[string]string{"c.txt": "1"}))
+
+	opts = f.options()
+	opts.Stale = true
+	statuses, err = List(context.Background(), f.repo, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := byName(statuses)["old"]; !ok || len(statuses) != 2 {
+		t.Errorf("--stale did not keep only old and main")
+	}
+}
+
+func TestListCachesComparisons(t *testing.T) {
+	f := newListFixture(t)
+
+	comparisons, err := Compare(context.Background(), f.repo, f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewCacheStore(CachePath(f.repo))
+	for _, comp := range comparisons {
+		cached := store[comp.CacheKey()]
+		if cached == nil {
+			t.Fatalf("%s is not cached", comp.Name())
+		}
+		if cached.Ahead != comp.Ahead || cached.Behind != comp.Behind || cached.IsMerged != comp.IsMerged {
+			t.Errorf("%s: cached %d/%d/%v, computed %d/%d/%v", comp.Name(),
+				cached.Ahead, cached.Behind, cached.IsMerged,
+				comp.Ahead, comp.Behind, comp.IsMerged)
+		}
+	}
+}
+
+func TestListUnwritableCache(t *testing.T) {
+	f := newListFixture(t)
+
+	// A regular file where the cache directory should be can't be written
+	// to, even as root.
+	notDir := filepath.Join(f.dir, "not-a-dir")
+	if err := ioutil.WriteFile(notDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := f.options()
+	opts.CachePath = filepath.Join(notDir, "cache")
+	statuses, err := List(context.Background(), f.repo, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 3 {
+		t.Errorf("got %d branches, want 3", len(statuses))
+	}
+}
+
+func TestListMissingBase(t *testing.T) {
+	f := newListFixture(t)
+
+	opts := f.options()
+	opts.Base = "does-not-exist"
+	if _, err := List(context.Background(), f.repo, opts); !errors.Is(err, ErrBaseNotFound) {
+		t.Fatalf("got %v, want ErrBaseNotFound", err)
+	}
+}
+
+func TestListPartialFailure(t *testing.T) {
+	f := newListFixture(t)
+
+	// A ref to a commit that isn't in the object database, as left by a
+	// partial clone.
+	missing := "1111111111111111111111111111111111111111\n"
+	path := filepath.Join(f.repo.Path(), "refs", "heads", "broken")
+	if err := ioutil.WriteFile(path, []byte(missing), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := List(context.Background(), f.repo, f.options())
+
+	var partial *PartialError
+	if !errors.As(err, &partial) {
+		t.Fatalf("got %v, want a *PartialError", err)
+	}
+	if len(partial.Errors) != 1 || partial.Errors[0].Branch != "broken" {
+		t.Fatalf("got errors %v, want one for 'broken'", partial.Errors)
+	}
+
+	got := byName(statuses)
+	if got["broken"].Err == nil {
+		t.Error("broken: expected Err to be set")
+	}
+	if feature := got["feature"]; feature.Err != nil || feature.Ahead != 2 || feature.Behind != 1 {
+		t.Errorf("feature: got %+v, want ahead 2, behind 1", feature)
+	}
+}
+
+func TestListCancelled(t *testing.T) {
+	f := newListFixture(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := List(ctx, f.repo, f.options()); err != context.Canceled {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+}
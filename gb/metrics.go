@@ -0,0 +1,129 @@
+package gb
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MetricsContentType is the media type of the text written by WriteMetrics.
+const MetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// AgeBuckets are the upper bounds of the branch age histogram.
+var AgeBuckets = []time.Duration{
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	StaleAfter,
+	30 * 24 * time.Hour,
+	90 * 24 * time.Hour,
+	180 * 24 * time.Hour,
+	365 * 24 * time.Hour,
+}
+
+// Branch states of the gb_branches metric.
+const (
+	MetricMerged = "merged"
+	MetricActive = "active"
+	MetricStale  = "stale"
+	MetricError  = "error"
+)
+
+// WriteMetrics writes branch hygiene metrics of the comparisons, other than
+// the base branch, in the OpenMetrics text format. Every sample is labeled
+// with the base branch and the repository path. Ages are measured at now.
+func WriteMetrics(w io.Writer, repoPath string, baseBranch string, comparisons Comparisons, now time.Time) error {
+	states := map[string]int{MetricMerged: 0, MetricActive: 0, MetricStale: 0, MetricError: 0}
+	authors := make(map[string]int)
+	buckets := make([]int, len(AgeBuckets))
+	ages := 0
+	ageSum := 0.0
+	maxBehind := 0
+
+	for _, comp := range comparisons {
+		if comp.IsBase(baseBranch) {
+			continue
+		}
+
+		if comp.Err != nil {
+			states[MetricError]++
+			continue
+		}
+
+		switch {
+		case comp.IsMerged:
+			states[MetricMerged]++
+		case comp.IsStale():
+			states[MetricStale]++
+		default:
+			states[MetricActive]++
+		}
+
+		if comp.Commit() != nil {
+			authors[comp.Commit().Author().Name]++
+		}
+
+		age := now.Sub(comp.When())
+		ages++
+		ageSum += age.Seconds()
+		for i, bound := range AgeBuckets {
+			if age <= bound {
+				buckets[i]++
+			}
+		}
+
+		if comp.Behind > maxBehind {
+			maxBehind = comp.Behind
+		}
+	}
+
+	labels := fmt.Sprintf(`base="%s",repo="%s"`, escapeLabel(baseBranch), escapeLabel(repoPath))
+
+	var b strings.Builder
+
+	b.WriteString("# TYPE gb_branches gauge\n")
+	b.WriteString("# HELP gb_branches Branches other than the base, by state.\n")
+	for _, state := range []string{MetricMerged, MetricActive, MetricStale, MetricError} {
+		fmt.Fprintf(&b, "gb_branches{%s,state=\"%s\"} %d\n", labels, state, states[state])
+	}
+
+	b.WriteString("# TYPE gb_branches_unmerged_stale gauge\n")
+	b.WriteString("# HELP gb_branches_unmerged_stale Unmerged branches without commits for two weeks.\n")
+	fmt.Fprintf(&b, "gb_branches_unmerged_stale{%s} %d\n", labels, states[MetricStale])
+
+	b.WriteString("# TYPE gb_branch_behind_max gauge\n")
+	b.WriteString("# HELP gb_branch_behind_max Largest number of base commits missing from a branch.\n")
+	fmt.Fprintf(&b, "gb_branch_behind_max{%s} %d\n", labels, maxBehind)
+
+	b.WriteString("# TYPE gb_branch_age_seconds histogram\n")
+	b.WriteString("# UNIT gb_branch_age_seconds seconds\n")
+	b.WriteString("# HELP gb_branch_age_seconds Time since the last commit of each branch.\n")
+	for i, bound := range AgeBuckets {
+		fmt.Fprintf(&b, "gb_branch_age_seconds_bucket{%s,le=\"%.0f\"} %d\n", labels, bound.Seconds(), buckets[i])
+	}
+	fmt.Fprintf(&b, "gb_branch_age_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, ages)
+	fmt.Fprintf(&b, "gb_branch_age_seconds_sum{%s} %.0f\n", labels, ageSum)
+	fmt.Fprintf(&b, "gb_branch_age_seconds_count{%s} %d\n", labels, ages)
+
+	names := make([]string, 0, len(authors))
+	for name := range authors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b.WriteString("# TYPE gb_author_branches gauge\n")
+	b.WriteString("# HELP gb_author_branches Branches whose last commit is by the author.\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "gb_author_branches{%s,author=\"%s\"} %d\n", labels, escapeLabel(name), authors[name])
+	}
+
+	b.WriteString("# EOF\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
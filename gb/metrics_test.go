@@ -0,0 +1,49 @@
+package gb
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	f := newListFixture(t)
+
+	f.when = time.Now().Add(-2 * StaleAfter)
+	f.branch("old", f.commit(nil, "old", map[string]string{"c.txt": "1"}))
+
+	opts := f.options()
+	opts.Flat = true
+	comparisons, err := Compare(context.Background(), f.repo, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteMetrics(&buf, "/src/\"repo\"", "main", comparisons, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	metrics := buf.String()
+
+	labels := `base="main",repo="/src/\"repo\""`
+	for _, want := range []string{
+		`gb_branches{` + labels + `,state="merged"} 1`,
+		`gb_branches{` + labels + `,state="active"} 1`,
+		`gb_branches{` + labels + `,state="stale"} 1`,
+		`gb_branches_unmerged_stale{` + labels + `} 1`,
+		`gb_branch_behind_max{` + labels + `} 3`,
+		`gb_branch_age_seconds_bucket{` + labels + `,le="86400"} 2`,
+		`gb_branch_age_seconds_bucket{` + labels + `,le="+Inf"} 3`,
+		`gb_author_branches{` + labels + `,author="gb"} 3`,
+	} {
+		if !strings.Contains(metrics, want+"\n") {
+			t.Errorf("missing %s in:\n%s", want, metrics)
+		}
+	}
+
+	if !strings.HasSuffix(metrics, "# EOF\n") {
+		t.Error("metrics do not end with # EOF")
+	}
+}
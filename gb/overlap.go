@@ -0,0 +1,170 @@
+package gb
+
+import (
+	"fmt"
+	"sort"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// ChangedPaths returns the set of paths the branch changed since its
+// merge-base with the base.
+func (c *Comparison) ChangedPaths() (map[string]bool, error) {
+	merge_base, err := c.Repo.MergeBase(c.BaseOid, c.Oid)
+	if git.IsErrorCode(err, git.ErrorCodeNotFound) {
+		return nil, fmt.Errorf("'%s' has no common history with the base", c.Name())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not find merge-base of '%s' and '%s': %w", c.BaseOid.String(), c.Oid.String(), err)
+	}
+
+	old_tree, err := lookupTree(c.Repo, merge_base)
+	if err != nil {
+		return nil, err
+	}
+
+	new_tree, err := lookupTree(c.Repo, c.Oid)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := c.Repo.DiffTreeToTree(old_tree, new_tree, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not diff '%s' against its merge-base: %w", c.Name(), err)
+	}
+	defer diff.Free()
+
+	deltas, err := diff.NumDeltas()
+	if err != nil {
+		return nil, fmt.Errorf("could not count changes of '%s': %w", c.Name(), err)
+	}
+
+	paths := make(map[string]bool)
+	for i := 0; i < deltas; i++ {
+		delta, err := diff.Delta(i)
+		if err != nil {
+			return nil, fmt.Errorf("could not read changes of '%s': %w", c.Name(), err)
+		}
+		paths[delta.OldFile.Path] = true
+		paths[delta.NewFile.Path] = true
+	}
+
+	return paths, nil
+}
+
+func lookupTree(repo *git.Repository, oid *git.Oid) (*git.Tree, error) {
+	commit, err := repo.LookupCommit(oid)
+	if err != nil {
+		return nil, fmt.Errorf("could not lookup commit '%s': %w", oid.String(), err)
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("could not lookup tree of '%s': %w", oid.String(), err)
+	}
+
+	return tree, nil
+}
+
+type Overlap struct {
+	A     *Comparison
+	B     *Comparison
+	Paths []string
+
+	ConflictsChecked bool
+	Conflicts        []string
+}
+
+type OverlapsByPaths []*Overlap
+
+func (a OverlapsByPaths) Len() int {
+	return len(a)
+}
+
+func (a OverlapsByPaths) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+func (a OverlapsByPaths) Less(i, j int) bool {
+	if len(a[i].Paths) != len(a[j].Paths) {
+		return len(a[i].Paths) > len(a[j].Paths)
+	}
+	return a[i].A.Name()+a[i].B.Name() < a[j].A.Name()+a[j].B.Name()
+}
+
+// FindOverlaps returns every pair of comparisons that change at least one
+// common path, most shared paths first. A branch stacked on the other one
+// contains its changes, so such pairs are left out.
+//
+// Branches whose changes can't be read, such as those without common history
+// with the base, get Err set and are left out, and a *PartialError is
+// returned along with the overlaps of the others.
+func FindOverlaps(comparisons Comparisons) ([]*Overlap, error) {
+	changed := make([]map[string]bool, len(comparisons))
+	for i, comp := range comparisons {
+		paths, err := comp.ChangedPaths()
+		if err != nil {
+			comp.fail(err)
+			continue
+		}
+		changed[i] = paths
+	}
+
+	overlaps := []*Overlap{}
+	for i := range comparisons {
+		if changed[i] == nil {
+			continue
+		}
+
+		for j := i + 1; j < len(comparisons); j++ {
+			if changed[j] == nil || comparisons[i].stackedWith(comparisons[j]) {
+				continue
+			}
+
+			paths := []string{}
+			for path := range changed[i] {
+				if changed[j][path] {
+					paths = append(paths, path)
+				}
+			}
+
+			if len(paths) == 0 {
+				continue
+			}
+
+			sort.Strings(paths)
+			overlaps = append(overlaps, &Overlap{A: comparisons[i], B: comparisons[j], Paths: paths})
+		}
+	}
+
+	sort.Sort(OverlapsByPaths(overlaps))
+	return overlaps, comparisons.Errors()
+}
+
+// stackedWith reports whether either branch is stacked, directly or not, on
+// the other.
+func (c *Comparison) stackedWith(other *Comparison) bool {
+	for parent := c.Parent; parent != nil; parent = parent.Parent {
+		if parent == other {
+			return true
+		}
+	}
+	for parent := other.Parent; parent != nil; parent = parent.Parent {
+		if parent == c {
+			return true
+		}
+	}
+	return false
+}
+
+// SetConflicts trial merges the two branches in memory.
+func (o *Overlap) SetConflicts() error {
+	paths, err := MergeConflicts(o.A.Repo, o.A.Oid, o.B.Oid)
+	if err != nil {
+		return fmt.Errorf("could not merge '%s' and '%s': %w", o.A.Name(), o.B.Name(), err)
+	}
+
+	o.Conflicts = paths
+	o.ConflictsChecked = true
+	return nil
+}
@@ -0,0 +1,59 @@
+package gb
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFindOverlaps(t *testing.T) {
+	f := newFixture(t)
+
+	c1 := f.commit(nil, "c1", map[string]string{"a.txt": "1"})
+	f.branch("main", c1)
+	f.checkout("main")
+
+	a1 := f.commit(c1, "a1", map[string]string{"shared.txt": "a"})
+	f.branch("feature-a", a1)
+
+	// Stacked on feature-a, so it contains shared.txt too.
+	a2 := f.commit(a1, "a2", map[string]string{"b.txt": "a"})
+	f.branch("feature-a-part2", a2)
+
+	b1 := f.commit(c1, "b1", map[string]string{"shared.txt": "b"})
+	f.branch("feature-b", b1)
+
+	pages := f.commit(nil, "pages", map[string]string{"shared.txt": "pages"})
+	f.branch("gh-pages", pages)
+
+	comparisons, err := Compare(context.Background(), f.repo, f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unmerged := Comparisons{}
+	for _, comp := range comparisons {
+		if comp.Err == nil && !comp.IsBase("main") && !comp.IsMerged {
+			unmerged = append(unmerged, comp)
+		}
+	}
+
+	overlaps, err := FindOverlaps(unmerged)
+	var partial *PartialError
+	if !errors.As(err, &partial) || len(partial.Errors) != 1 || partial.Errors[0].Branch != "gh-pages" {
+		t.Fatalf("got %v, want gh-pages to be skipped", err)
+	}
+
+	got := map[string]bool{}
+	for _, o := range overlaps {
+		got[o.A.Name()+" "+o.B.Name()] = true
+		got[o.B.Name()+" "+o.A.Name()] = true
+	}
+
+	if len(overlaps) != 2 || !got["feature-a feature-b"] || !got["feature-a-part2 feature-b"] {
+		t.Errorf("got overlaps %v, want feature-b against feature-a and feature-a-part2", got)
+	}
+	if got["feature-a feature-a-part2"] {
+		t.Error("feature-a-part2 is stacked on feature-a and should not overlap it")
+	}
+}
@@ -0,0 +1,289 @@
+package gb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// PolicyFile is the naming policy committed at the root of the repository.
+// Each line is a `key = value` setting, with the keys of the gb.policy.*
+// config: pattern, prefix (repeatable), maxlength and forbidden.
+const PolicyFile = ".gbpolicy"
+
+// Naming policy configuration keys, which override the PolicyFile.
+const (
+	PolicyPatternKey   = "gb.policy.pattern"
+	PolicyPrefixKey    = "gb.policy.prefix"
+	PolicyMaxLengthKey = "gb.policy.maxlength"
+	PolicyForbiddenKey = "gb.policy.forbidden"
+)
+
+// Policy is the set of rules branch names must follow. Zero values don't
+// check anything.
+type Policy struct {
+	Pattern   *regexp.Regexp
+	Prefixes  []string
+	MaxLength int
+	Forbidden string
+}
+
+// LoadPolicy reads the PolicyFile from the working directory, or from HEAD in
+// a bare repository, then the gb.policy.* config on top of it. Prefixes from
+// the config replace the ones of the file.
+func LoadPolicy(repo *git.Repository) (*Policy, error) {
+	settings := make(map[string][]string)
+
+	content, err := readPolicyFile(repo)
+	if err != nil {
+		return nil, err
+	}
+	if err := parsePolicy(strings.NewReader(content), settings); err != nil {
+		return nil, fmt.Errorf("%s: %w", PolicyFile, err)
+	}
+
+	config, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	for key, name := range map[string]string{
+		"pattern":   PolicyPatternKey,
+		"maxlength": PolicyMaxLengthKey,
+		"forbidden": PolicyForbiddenKey,
+	} {
+		if value, err := config.LookupString(name); err == nil {
+			settings[key] = []string{value}
+		}
+	}
+
+	if iterator, err := config.NewMultivarIterator(PolicyPrefixKey, ""); err == nil {
+		prefixes := []string{}
+		for {
+			entry, err := iterator.Next()
+			if err != nil {
+				break
+			}
+			prefixes = append(prefixes, entry.Value)
+		}
+		iterator.Free()
+
+		if len(prefixes) > 0 {
+			settings["prefix"] = prefixes
+		}
+	}
+
+	return newPolicy(settings)
+}
+
+// readPolicyFile returns the content of the PolicyFile, or "" when there is
+// none.
+func readPolicyFile(repo *git.Repository) (string, error) {
+	if repo.Workdir() != "" {
+		bits, err := ioutil.ReadFile(filepath.Join(repo.Workdir(), PolicyFile))
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return string(bits), err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return "", nil
+	}
+	commit, err := repo.LookupCommit(head.Target())
+	if err != nil {
+		return "", nil
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return "", err
+	}
+	entry := tree.EntryByName(PolicyFile)
+	if entry == nil {
+		return "", nil
+	}
+	blob, err := repo.LookupBlob(entry.Id)
+	if err != nil {
+		return "", err
+	}
+	return string(blob.Contents()), nil
+}
+
+// parsePolicy adds the `key = value` lines of r to settings, skipping blank
+// lines and # comments.
+func parsePolicy(r io.Reader, settings map[string][]string) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(text, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("line %d: expected key = value", line)
+		}
+
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.TrimSpace(parts[1])
+
+		switch key {
+		case "prefix":
+			settings[key] = append(settings[key], value)
+		case "pattern", "maxlength", "forbidden":
+			settings[key] = []string{value}
+		default:
+			return fmt.Errorf("line %d: unknown key '%s'", line, key)
+		}
+	}
+	return scanner.Err()
+}
+
+func newPolicy(settings map[string][]string) (*Policy, error) {
+	p := &Policy{Prefixes: settings["prefix"]}
+
+	if values := settings["pattern"]; len(values) > 0 && values[0] != "" {
+		pattern, err := regexp.Compile(values[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		p.Pattern = pattern
+	}
+
+	if values := settings["maxlength"]; len(values) > 0 && values[0] != "" {
+		max, err := strconv.Atoi(values[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid maxlength '%s'", values[0])
+		}
+		p.MaxLength = max
+	}
+
+	if values := settings["forbidden"]; len(values) > 0 {
+		p.Forbidden = strings.Replace(values[0], " ", "", -1)
+	}
+
+	return p, nil
+}
+
+// Check returns the rules the name breaks, or nothing when it follows the
+// policy.
+func (p *Policy) Check(name string) []string {
+	problems := []string{}
+
+	if p.Pattern != nil && !p.Pattern.MatchString(name) {
+		problems = append(problems, fmt.Sprintf("does not match %s", p.Pattern))
+	}
+
+	if len(p.Prefixes) > 0 {
+		allowed := false
+		for _, prefix := range p.Prefixes {
+			if strings.HasPrefix(name, prefix) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			problems = append(problems, "does not start with "+strings.Join(p.Prefixes, ", "))
+		}
+	}
+
+	if p.MaxLength > 0 && utf8.RuneCountInString(name) > p.MaxLength {
+		problems = append(problems, fmt.Sprintf("longer than %d characters", p.MaxLength))
+	}
+
+	if i := strings.IndexAny(name, p.Forbidden); p.Forbidden != "" && i >= 0 {
+		r, _ := utf8.DecodeRuneInString(name[i:])
+		problems = append(problems, fmt.Sprintf("contains forbidden character '%c'", r))
+	}
+
+	return problems
+}
+
+// Violation is a branch whose name breaks the policy.
+type Violation struct {
+	Branch   string
+	Problems []string
+}
+
+// Lint checks the name of every local branch, or of every branch of the remote
+// when remoteName is set, without the remote. Branches for which skip returns
+// true, such as protected ones, are not checked.
+func (p *Policy) Lint(repo *git.Repository, remoteName string, skip func(string) bool) ([]Violation, error) {
+	flags := git.BranchLocal
+	prefix := ""
+	if remoteName != "" {
+		flags = git.BranchRemote
+		prefix = remoteName + "/"
+	}
+
+	branch_iterator, err := repo.NewBranchIterator(flags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches for '%s': %w", repo.Workdir(), err)
+	}
+	defer branch_iterator.Free()
+
+	violations := []Violation{}
+	err = branch_iterator.ForEach(func(branch *git.Branch, _ git.BranchType) error {
+		name, err := branch.Name()
+		if err != nil {
+			return err
+		}
+
+		if branch.Type() == git.ReferenceSymbolic || !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		name = strings.TrimPrefix(name, prefix)
+
+		if skip != nil && skip(name) {
+			return nil
+		}
+
+		if problems := p.Check(name); len(problems) > 0 {
+			violations = append(violations, Violation{Branch: name, Problems: problems})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+
+	return violations, nil
+}
+
+// NewBranch creates the branch at the tip of the base after checking its name
+// against the policy. The error wraps ErrPolicyViolation when it doesn't
+// follow it.
+func NewBranch(repo *git.Repository, name string, baseBranch string, policy *Policy) (*git.Branch, error) {
+	if problems := policy.Check(name); len(problems) > 0 {
+		return nil, fmt.Errorf("%w: '%s' %s", ErrPolicyViolation, name, strings.Join(problems, ", "))
+	}
+
+	if _, err := repo.LookupBranch(name, git.BranchLocal); err == nil {
+		return nil, fmt.Errorf("branch '%s' already exists", name)
+	}
+
+	base_oid, err := LookupBaseOid(repo, baseBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := repo.LookupCommit(base_oid)
+	if err != nil {
+		return nil, fmt.Errorf("could not lookup commit '%s': %w", base_oid.String(), err)
+	}
+
+	branch, err := repo.CreateBranch(name, commit, false)
+	if err != nil {
+		return nil, fmt.Errorf("could not create branch '%s': %w", name, err)
+	}
+	return branch, nil
+}
@@ -0,0 +1,95 @@
+package gb
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPolicy = `# <type>/<ticket>-<slug>
+pattern = ^[a-z]+/[A-Z]+-[0-9]+-[a-z0-9-]+$
+prefix = feat/
+prefix = fix/
+maxlength = 30
+forbidden = _ @
+`
+
+func TestPolicyCheck(t *testing.T) {
+	settings := make(map[string][]string)
+	if err := parsePolicy(strings.NewReader(testPolicy), settings); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := newPolicy(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if problems := policy.Check("feat/GB-12-add-lint"); len(problems) != 0 {
+		t.Errorf("valid name: got %v", problems)
+	}
+
+	problems := policy.Check("chore/GB-12-a_very-long-name-for-a-branch")
+	want := []string{
+		"does not match ^[a-z]+/[A-Z]+-[0-9]+-[a-z0-9-]+$",
+		"does not start with feat/, fix/",
+		"longer than 30 characters",
+		"contains forbidden character '_'",
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("got %q, want %q", problems, want)
+	}
+
+	if problems := policy.Check("fix/oops"); len(problems) != 1 {
+		t.Errorf("pattern: got %v, want one problem", problems)
+	}
+}
+
+func TestLintAndNewBranch(t *testing.T) {
+	f := newListFixture(t)
+	if err := ioutil.WriteFile(filepath.Join(f.dir, PolicyFile), []byte(testPolicy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The config replaces the prefixes of the file.
+	f.config().SetMultivar(PolicyPrefixKey, "^$", "feature")
+
+	policy, err := LoadPolicy(f.repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(policy.Prefixes, []string{"feature"}) || policy.MaxLength != 30 {
+		t.Fatalf("got %+v", policy)
+	}
+
+	protection, err := NewProtection(f.repo, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	violations, err := policy.Lint(f.repo, "", protection.Matches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, violation := range violations {
+		names[violation.Branch] = true
+	}
+	if len(names) != 2 || !names["feature"] || !names["merged"] {
+		t.Errorf("got %+v, want feature and merged", violations)
+	}
+
+	if _, err := NewBranch(f.repo, "fix_it", "main", policy); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("got %v, want ErrPolicyViolation", err)
+	}
+
+	branch, err := NewBranch(f.repo, "feature/GB-1-lint", "main", policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !branch.Target().Equal(mustTarget(t, f, "main")) {
+		t.Error("the new branch is not at the tip of main")
+	}
+}
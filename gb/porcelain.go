@@ -0,0 +1,48 @@
+package gb
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Porcelain states, the first field of a porcelain line.
+const (
+	PorcelainBase     = "base"
+	PorcelainMerged   = "merged"
+	PorcelainUnmerged = "unmerged"
+	PorcelainError    = "error"
+)
+
+// Porcelain formats the status as a single line meant for scripts. Its fields
+// are separated by single spaces and never change between versions:
+//
+//	<state> <name> <oid> <ahead> <behind> <committer-unix-time> <parent>
+//
+// The state is base, merged, unmerged or error. Ahead and behind are counted
+// against the base, never the parent. Unknown values are written as "-".
+func (s BranchStatus) Porcelain() string {
+	state := PorcelainUnmerged
+	switch {
+	case s.Err != nil:
+		state = PorcelainError
+	case s.IsBase:
+		state = PorcelainBase
+	case s.IsMerged:
+		state = PorcelainMerged
+	}
+
+	oid, ahead, behind, when, parent := "-", "-", "-", "-", "-"
+	if s.Oid != "" {
+		oid = s.Oid
+	}
+	if s.Err == nil {
+		ahead = strconv.Itoa(s.Ahead)
+		behind = strconv.Itoa(s.Behind)
+		when = strconv.FormatInt(s.When.Unix(), 10)
+	}
+	if s.Parent != "" {
+		parent = s.Parent
+	}
+
+	return fmt.Sprintf("%s %s %s %s %s %s %s", state, s.Name, oid, ahead, behind, when, parent)
+}
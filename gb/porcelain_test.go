@@ -0,0 +1,34 @@
+package gb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestPorcelain(t *testing.T) {
+	f := newListFixture(t)
+
+	statuses, err := List(context.Background(), f.repo, f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := byName(statuses)
+	for name, want := range map[string]string{
+		"main":    "base main %s 0 0 %d -",
+		"merged":  "merged merged %s 0 1 %d -",
+		"feature": "unmerged feature %s 2 1 %d -",
+	} {
+		s := got[name]
+		want = fmt.Sprintf(want, s.Oid, s.When.Unix())
+		if line := s.Porcelain(); line != want {
+			t.Errorf("%s: got %q, want %q", name, line, want)
+		}
+	}
+
+	broken := BranchStatus{Name: "broken", Err: &BranchError{Branch: "broken"}}
+	if line := broken.Porcelain(); line != "error broken - - - - -" {
+		t.Errorf("broken: got %q", line)
+	}
+}
@@ -0,0 +1,226 @@
+package gb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// Pull request states.
+const (
+	PullRequestOpen   = "open"
+	PullRequestDraft  = "draft"
+	PullRequestMerged = "merged"
+	PullRequestClosed = "closed"
+)
+
+// Review statuses of open pull requests.
+const (
+	ReviewApproved         = "approved"
+	ReviewChangesRequested = "changes_requested"
+	ReviewRequired         = "review_required"
+)
+
+// Combined commit statuses. A commit without any status has an empty one.
+const (
+	CISuccess = "success"
+	CIFailure = "failure"
+	CIPending = "pending"
+)
+
+// PullRequestTTL is how long pull requests, and commit statuses that are still
+// pending, are cached. Successes and failures are cached forever.
+const PullRequestTTL = 5 * time.Minute
+
+// PullRequest is the latest pull or merge request opened from a branch.
+type PullRequest struct {
+	Number int
+	State  string
+	Review string
+	URL    string
+}
+
+// Provider looks up pull requests and commit statuses on a hosting service.
+type Provider interface {
+	// Name identifies the provider and repository in cache keys.
+	Name() string
+
+	// PullRequest returns the latest pull request from the branch, or nil
+	// when there is none.
+	PullRequest(branch string) (*PullRequest, error)
+
+	// CommitStatus returns the combined status of the CI of the commit:
+	// CISuccess, CIFailure, CIPending, or "" when there is none.
+	CommitStatus(oid string) (string, error)
+}
+
+// Hosting provider configuration keys.
+const (
+	ProviderKey           = "gb.provider"
+	ProviderURLKey        = "gb.providerurl"
+	ProviderRepositoryKey = "gb.repository"
+)
+
+// TokenEnv is the environment variable holding the provider token. When it
+// is empty, GITHUB_TOKEN or GITLAB_TOKEN is used depending on the provider.
+const TokenEnv = "GB_TOKEN"
+
+// NewProvider returns the provider configured in `gb.provider`, github or
+// gitlab. The API is at `gb.providerurl`, defaulting to the public service,
+// and the repository is `gb.repository`, defaulting to the path of the origin
+// remote.
+func NewProvider(repo *git.Repository) (Provider, error) {
+	config, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	kind, _ := config.LookupString(ProviderKey)
+	baseURL, _ := config.LookupString(ProviderURLKey)
+	repository, _ := config.LookupString(ProviderRepositoryKey)
+
+	if repository == "" {
+		repository, err = originRepository(repo)
+		if err != nil {
+			return nil, fmt.Errorf("could not tell the repository from the origin remote, set %s: %w", ProviderRepositoryKey, err)
+		}
+	}
+
+	token := os.Getenv(TokenEnv)
+
+	switch kind {
+	case "github":
+		if token == "" {
+			token = os.Getenv("GITHUB_TOKEN")
+		}
+		return NewGitHub(baseURL, repository, token), nil
+	case "gitlab":
+		if token == "" {
+			token = os.Getenv("GITLAB_TOKEN")
+		}
+		return NewGitLab(baseURL, repository, token), nil
+	case "":
+		return nil, fmt.Errorf("no hosting provider configured, set %s to github or gitlab", ProviderKey)
+	}
+	return nil, fmt.Errorf("unknown hosting provider '%s', expected github or gitlab", kind)
+}
+
+var remotePathPattern = regexp.MustCompile(`^(?:[a-z+]+://[^/]+/|[^@/]+@[^:]+:)(.+?)(?:\.git)?/?$`)
+
+// originRepository returns the repository path of the origin remote, such as
+// "owner/name" for git@github.com:owner/name.git.
+func originRepository(repo *git.Repository) (string, error) {
+	remote, err := repo.Remotes.Lookup("origin")
+	if err != nil {
+		return "", err
+	}
+	defer remote.Free()
+
+	return RemoteRepository(remote.Url())
+}
+
+// RemoteRepository extracts the repository path from a remote URL.
+func RemoteRepository(url string) (string, error) {
+	match := remotePathPattern.FindStringSubmatch(url)
+	if match == nil {
+		return "", fmt.Errorf("unsupported remote url '%s'", url)
+	}
+	return match[1], nil
+}
+
+// SetPullRequests looks up the pull request of every branch other than the
+// base through the cache. A branch whose pull request was merged counts as
+// merged, whatever the commit graph says. A failed lookup is recorded in the
+// Err of its branch.
+func (cs Comparisons) SetPullRequests(provider Provider, cache ProviderCache, baseBranch string) {
+	for _, comp := range cs {
+		if comp.Err != nil || comp.IsBase(baseBranch) {
+			continue
+		}
+
+		key := provider.Name() + " pr " + comp.Name()
+
+		var pr *PullRequest
+		if !cache.Get(key, &pr) {
+			var err error
+			pr, err = provider.PullRequest(comp.Name())
+			if err != nil {
+				comp.fail(fmt.Errorf("could not look up the pull request: %w", err))
+				continue
+			}
+			cache.Set(key, pr, PullRequestTTL)
+		}
+
+		comp.PullRequest = pr
+		if pr != nil && pr.State == PullRequestMerged {
+			comp.IsMerged = true
+		}
+	}
+}
+
+// SetCommitStatuses looks up the combined commit status of the tip of every
+// branch through the cache. A failed lookup is recorded in the Err of its
+// branch.
+func (cs Comparisons) SetCommitStatuses(provider Provider, cache ProviderCache) {
+	for _, comp := range cs {
+		if comp.Err != nil {
+			continue
+		}
+
+		key := provider.Name() + " ci " + comp.Oid.String()
+
+		var status string
+		if !cache.Get(key, &status) {
+			var err error
+			status, err = provider.CommitStatus(comp.Oid.String())
+			if err != nil {
+				comp.fail(fmt.Errorf("could not look up the commit status: %w", err))
+				continue
+			}
+
+			ttl := PullRequestTTL
+			if status == CISuccess || status == CIFailure {
+				ttl = 0
+			}
+			cache.Set(key, status, ttl)
+		}
+
+		comp.CI = status
+	}
+}
+
+// getJSON decodes the JSON response to a GET request with the given headers.
+func getJSON(client *http.Client, url string, headers map[string]string, v interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	for name, value := range headers {
+		if value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("GET %s: %s: %s", url, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,203 @@
+package gb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// standIn serves canned JSON bodies by request path and query.
+func standIn(t *testing.T, responses map[string]string, header string, token string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(header) != token {
+			t.Errorf("%s: got %s %q, want %q", r.URL, header, r.Header.Get(header), token)
+		}
+
+		body, ok := responses[r.URL.EscapedPath()+"?"+r.URL.RawQuery]
+		if !ok {
+			body, ok = responses[r.URL.EscapedPath()]
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGitHub(t *testing.T) {
+	server := standIn(t, map[string]string{
+		"/repos/acme/app/pulls?head=acme%3Afeature&per_page=1&state=all": `[{"number": 7, "state": "open", "draft": false, "html_url": "https://example.com/7"}]`,
+		"/repos/acme/app/pulls/7/reviews": `[
+			{"user": {"login": "a"}, "state": "CHANGES_REQUESTED"},
+			{"user": {"login": "b"}, "state": "COMMENTED"},
+			{"user": {"login": "a"}, "state": "APPROVED"}
+		]`,
+		"/repos/acme/app/pulls?head=acme%3Adone&per_page=1&state=all": `[{"number": 3, "state": "closed", "merged_at": "2020-01-01T00:00:00Z"}]`,
+		"/repos/acme/app/pulls?head=acme%3Anone&per_page=1&state=all": `[]`,
+	}, "Authorization", "Bearer secret")
+
+	github := NewGitHub(server.URL, "acme/app", "secret")
+
+	pr, err := github.PullRequest("feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.Number != 7 || pr.State != PullRequestOpen || pr.Review != ReviewApproved {
+		t.Errorf("feature: got %+v, want #7 open approved", pr)
+	}
+
+	pr, err = github.PullRequest("done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.State != PullRequestMerged || pr.Review != "" {
+		t.Errorf("done: got %+v, want merged without review", pr)
+	}
+
+	if pr, err := github.PullRequest("none"); err != nil || pr != nil {
+		t.Errorf("none: got %+v, %v, want no pull request", pr, err)
+	}
+
+	if _, err := github.PullRequest("missing"); err == nil {
+		t.Error("expected an error for a 404")
+	}
+}
+
+func TestGitLab(t *testing.T) {
+	server := standIn(t, map[string]string{
+		"/projects/group%2Fsub%2Fapp/merge_requests?order_by=created_at&per_page=1&source_branch=feature&state=all": `[{"iid": 12, "state": "opened", "draft": true, "web_url": "https://example.com/12"}]`,
+		"/projects/group%2Fsub%2Fapp/merge_requests/12/approvals":                                                   `{"approved": false}`,
+	}, "PRIVATE-TOKEN", "secret")
+
+	gitlab := NewGitLab(server.URL, "group/sub/app", "secret")
+
+	pr, err := gitlab.PullRequest("feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.Number != 12 || pr.State != PullRequestDraft || pr.Review != ReviewRequired {
+		t.Errorf("feature: got %+v, want !12 draft review_required", pr)
+	}
+}
+
+func TestRemoteRepository(t *testing.T) {
+	for url, want := range map[string]string{
+		"git@github.com:acme/app.git":               "acme/app",
+		"https://github.com/acme/app":               "acme/app",
+		"https://gitlab.com/group/sub/app.git":      "group/sub/app",
+		"ssh://git@gitlab.example.com:22/g/app.git": "g/app",
+	} {
+		if got, err := RemoteRepository(url); err != nil || got != want {
+			t.Errorf("%s: got %q, %v, want %q", url, got, err, want)
+		}
+	}
+
+	if _, err := RemoteRepository("/srv/git/app.git"); err == nil {
+		t.Error("expected an error for a local path")
+	}
+}
+
+// fakeProvider answers from its maps, and fails for the branches and oids in
+// failing.
+type fakeProvider struct {
+	pulls    map[string]*PullRequest
+	statuses map[string]string
+	failing  map[string]bool
+	calls    int
+}
+
+func (p *fakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeProvider) PullRequest(branch string) (*PullRequest, error) {
+	p.calls++
+	if p.failing[branch] {
+		return nil, errors.New("rate limited")
+	}
+	return p.pulls[branch], nil
+}
+
+func (p *fakeProvider) CommitStatus(oid string) (string, error) {
+	p.calls++
+	if p.failing[oid] {
+		return "", errors.New("timeout")
+	}
+	return p.statuses[oid], nil
+}
+
+func TestMergedPullRequest(t *testing.T) {
+	f := newListFixture(t)
+
+	provider := &fakeProvider{pulls: map[string]*PullRequest{
+		"feature": {Number: 1, State: PullRequestMerged},
+	}}
+
+	opts := f.options()
+	opts.Provider = provider
+	opts.PullRequests = true
+	statuses, err := List(context.Background(), f.repo, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feature := byName(statuses)["feature"]
+	if !feature.IsMerged || feature.PullRequest == nil || feature.PullRequest.Number != 1 {
+		t.Errorf("feature: got %+v, want merged through #1", feature)
+	}
+
+	// Branches without a pull request are cached too.
+	calls := provider.calls
+	if _, err := List(context.Background(), f.repo, opts); err != nil {
+		t.Fatal(err)
+	}
+	if provider.calls != calls {
+		t.Errorf("got %d more lookups, want every answer from the cache", provider.calls-calls)
+	}
+
+	// The comparison cache keeps the graph's answer.
+	statuses, err = List(context.Background(), f.repo, f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byName(statuses)["feature"].IsMerged {
+		t.Error("merged pull request leaked into the comparison cache")
+	}
+}
+
+func TestPullRequestLookupFailure(t *testing.T) {
+	f := newListFixture(t)
+
+	provider := &fakeProvider{
+		pulls:   map[string]*PullRequest{"merged": {Number: 2, State: PullRequestOpen}},
+		failing: map[string]bool{"feature": true},
+	}
+
+	opts := f.options()
+	opts.Provider = provider
+	opts.PullRequests = true
+	statuses, err := List(context.Background(), f.repo, opts)
+	var partial *PartialError
+	if !errors.As(err, &partial) || len(partial.Errors) != 1 || partial.Errors[0].Branch != "feature" {
+		t.Fatalf("got %v, want feature to fail alone", err)
+	}
+
+	got := byName(statuses)
+	if got["feature"].Err == nil {
+		t.Error("feature: the failed lookup is not recorded")
+	}
+	if pr := got["merged"].PullRequest; pr == nil || pr.Number != 2 {
+		t.Errorf("merged: got %+v, want #2", pr)
+	}
+
+	cache := NewProviderCache(ProviderCachePath(f.repo))
+	if _, ok := cache[provider.Name()+" pr merged"]; !ok {
+		t.Error("the successful lookup was not cached")
+	}
+}
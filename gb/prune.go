@@ -0,0 +1,163 @@
+package gb
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// ProtectedKey is the multi-valued git config key of the branch name patterns
+// that are never pruned, such as `release/*`.
+const ProtectedKey = "gb.protected"
+
+// DefaultProtected are protected on top of the patterns of ProtectedKey.
+var DefaultProtected = []string{"main", "master", "develop"}
+
+// Protection decides which branches may be deleted. The base branch and the
+// branches checked out in a worktree are always protected. Base is the local
+// branch of a remote base, see LocalBase.
+type Protection struct {
+	Base       string
+	Patterns   []string
+	CheckedOut map[string]Worktree
+}
+
+// NewProtection reads the protected patterns of the repository.
+func NewProtection(repo *git.Repository, baseBranch string) (*Protection, error) {
+	p := &Protection{
+		Base:       LocalBase(repo, baseBranch),
+		Patterns:   append([]string{}, DefaultProtected...),
+		CheckedOut: CheckedOut(repo),
+	}
+
+	config, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := config.NewMultivarIterator(ProtectedKey, "")
+	if err != nil {
+		return p, nil
+	}
+	defer iterator.Free()
+
+	for {
+		entry, err := iterator.Next()
+		if git.IsErrorCode(err, git.ErrorCodeIterOver) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", ProtectedKey, err)
+		}
+		p.Patterns = append(p.Patterns, entry.Value)
+	}
+
+	return p, nil
+}
+
+// Protected reports whether the local branch name may not be deleted.
+func (p *Protection) Protected(name string) bool {
+	if name == p.Base {
+		return true
+	}
+
+	if _, ok := p.CheckedOut["refs/heads/"+name]; ok {
+		return true
+	}
+
+	return p.Matches(name)
+}
+
+// Matches reports whether the name matches one of the protected patterns,
+// ignoring checkouts. Remote branches are only protected by their patterns.
+func (p *Protection) Matches(name string) bool {
+	if name == p.Base {
+		return true
+	}
+
+	for _, pattern := range p.Patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// PruneCandidate is a branch that can be deleted.
+type PruneCandidate struct {
+	*Comparison
+
+	// Name is the branch name, without the remote for remote branches.
+	Name     string
+	Squashed bool
+}
+
+// Reason is why the branch can be deleted.
+func (c PruneCandidate) Reason() string {
+	if c.Squashed {
+		return "squash-merged"
+	}
+	return "merged"
+}
+
+// Warnings are what would be lost or left behind by deleting the branch:
+// stashes created on it and uncommitted changes in its worktree.
+func (c PruneCandidate) Warnings() []string {
+	warnings := []string{}
+	if c.Stashes == 1 {
+		warnings = append(warnings, "1 stash")
+	} else if c.Stashes > 1 {
+		warnings = append(warnings, fmt.Sprintf("%d stashes", c.Stashes))
+	}
+	if c.Dirty != nil && c.Dirty.IsDirty() {
+		warnings = append(warnings, "uncommitted changes: "+c.Dirty.String())
+	}
+	return warnings
+}
+
+// PruneCandidates returns the branches that are merged into the base, or
+// squash-merged when squash is set. Names have the prefix trimmed, and
+// protected reports whether a name is protected.
+func PruneCandidates(comparisons Comparisons, prefix string, squash bool, protected func(string) bool) ([]PruneCandidate, error) {
+	candidates := []PruneCandidate{}
+
+	for _, comp := range comparisons {
+		name := strings.TrimPrefix(comp.Name(), prefix)
+		if comp.Err != nil || protected(name) {
+			continue
+		}
+
+		if comp.IsMerged {
+			candidates = append(candidates, PruneCandidate{Comparison: comp, Name: name})
+			continue
+		}
+
+		if !squash {
+			continue
+		}
+
+		squashed, err := comp.IsSquashMerged()
+		if err != nil {
+			return nil, err
+		}
+		if squashed {
+			candidates = append(candidates, PruneCandidate{Comparison: comp, Name: name, Squashed: true})
+		}
+	}
+
+	return candidates, nil
+}
+
+// PushDeletions deletes the branches on the remote in a single push of
+// `:refs/heads/<name>` refspecs. Push progress is written to progress when it
+// isn't nil.
+func PushDeletions(repo *git.Repository, remoteName string, names []string, progress io.Writer) ([]PushResult, error) {
+	refspecs := make([]string, len(names))
+	for i, name := range names {
+		refspecs[i] = ":refs/heads/" + name
+	}
+	return Push(repo, remoteName, refspecs, progress)
+}
@@ -0,0 +1,162 @@
+package gb
+
+import (
+	"context"
+	"testing"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// newPruneFixture creates:
+//
+//	main:     c1 - c2 - s1
+//	merged:        c2
+//	squashed:      c2 - q1 - q2   (same changes as s1)
+//	feature:       c2 - f1
+//	release/1:     c2
+func newPruneFixture(t *testing.T) *fixture {
+	f := newFixture(t)
+
+	c1 := f.commit(nil, "c1", map[string]string{"a.txt": "1"})
+	c2 := f.commit(c1, "c2", map[string]string{"a.txt": "2"})
+	f.branch("merged", c2)
+	f.branch("release/1", c2)
+
+	q1 := f.commit(c2, "q1", map[string]string{"b.txt": "1"})
+	q2 := f.commit(q1, "q2", map[string]string{"b.txt": "2"})
+	f.branch("squashed", q2)
+
+	f1 := f.commit(c2, "f1", map[string]string{"c.txt": "1"})
+	f.branch("feature", f1)
+
+	s1 := f.commit(c2, "squash of q1 and q2", map[string]string{"b.txt": "2"})
+	f.branch("main", s1)
+	f.checkout("main")
+
+	return f
+}
+
+func candidateNames(candidates []PruneCandidate) map[string]string {
+	m := make(map[string]string)
+	for _, c := range candidates {
+		m[c.Name] = c.Reason()
+	}
+	return m
+}
+
+func TestPruneCandidates(t *testing.T) {
+	f := newPruneFixture(t)
+
+	config, err := f.repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.SetMultivar(ProtectedKey, "^$", "release/*")
+
+	opts := f.options()
+	opts.Flat = true
+	comparisons, err := Compare(context.Background(), f.repo, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protection, err := NewProtection(f.repo, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := candidateNames(mustCandidates(t, comparisons, true, protection.Protected))
+	want := map[string]string{"merged": "merged", "squashed": "squash-merged"}
+	if len(got) != len(want) || got["merged"] != want["merged"] || got["squashed"] != want["squashed"] {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	got = candidateNames(mustCandidates(t, comparisons, false, protection.Protected))
+	if len(got) != 1 || got["merged"] != "merged" {
+		t.Errorf("without squash: got %v, want only merged", got)
+	}
+}
+
+func mustCandidates(t *testing.T, comparisons Comparisons, squash bool, protected func(string) bool) []PruneCandidate {
+	candidates, err := PruneCandidates(comparisons, "", squash, protected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return candidates
+}
+
+func TestProtection(t *testing.T) {
+	f := newPruneFixture(t)
+	f.checkout("feature")
+
+	protection, err := NewProtection(f.repo, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]bool{"main": true, "master": true, "feature": true, "merged": false} {
+		if got := protection.Protected(name); got != want {
+			t.Errorf("Protected(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	if protection.Matches("feature") {
+		t.Error("Matches should ignore checked out branches")
+	}
+}
+
+func TestPushDeletions(t *testing.T) {
+	upstream := newBareFixture(t)
+	c1 := upstream.commit(nil, "c1", map[string]string{"a.txt": "1"})
+	c2 := upstream.commit(c1, "c2", map[string]string{"a.txt": "2"})
+	upstream.branch("main", c2)
+	upstream.branch("merged", c1)
+	f1 := upstream.commit(c2, "f1", map[string]string{"b.txt": "1"})
+	upstream.branch("feature", f1)
+
+	f := newFixture(t)
+	if _, err := f.repo.Remotes.Create("origin", upstream.dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Fetch(f.repo, []string{"origin"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	f.branch("main", c2)
+
+	comparisons, err := CompareRemote(context.Background(), f.repo, "origin", f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protection, err := NewProtection(f.repo, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	candidates, err := PruneCandidates(comparisons, "origin/", true, protection.Matches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := candidateNames(candidates); len(got) != 1 || got["merged"] != "merged" {
+		t.Fatalf("got %v, want only merged", got)
+	}
+
+	results, err := PushDeletions(f.repo, "origin", []string{candidates[0].Name}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Ref != "refs/heads/merged" || results[0].Status != "" {
+		t.Errorf("got %+v, want merged deleted", results)
+	}
+
+	if _, err := upstream.repo.LookupBranch("merged", git.BranchLocal); err == nil {
+		t.Error("merged still exists upstream")
+	}
+	if f.remoteTip("origin/merged") != "" {
+		t.Error("origin/merged was not removed")
+	}
+	if _, err := upstream.repo.LookupBranch("feature", git.BranchLocal); err != nil {
+		t.Error("feature was deleted upstream")
+	}
+}
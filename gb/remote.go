@@ -0,0 +1,272 @@
+package gb
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// Values of Options.Fetch.
+const (
+	FetchBase = "base"
+	FetchAll  = "all"
+)
+
+// SSHKeyFiles are the private keys tried, in order, from ~/.ssh when the
+// ssh-agent has no usable key.
+var SSHKeyFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// errNoCredentials stops libgit2 from asking for credentials forever.
+var errNoCredentials = errors.New("no more credentials to try")
+
+// credentials answers the credentials callback of libgit2, which is called
+// again after each rejected attempt: the ssh-agent first, then the key files,
+// and the git credential helper for HTTP.
+type credentials struct {
+	sshAttempts  int
+	helperTried  bool
+	usernameSent bool
+}
+
+func (c *credentials) callback(remoteURL string, username string, allowed git.CredentialType) (*git.Credential, error) {
+	if username == "" {
+		username = "git"
+	}
+
+	if allowed&git.CredentialTypeUsername != 0 && !c.usernameSent {
+		c.usernameSent = true
+		return git.NewCredentialUsername(username)
+	}
+
+	if allowed&git.CredentialTypeSSHKey != 0 {
+		for {
+			attempt := c.sshAttempts
+			c.sshAttempts++
+
+			if attempt == 0 {
+				if os.Getenv("SSH_AUTH_SOCK") != "" {
+					return git.NewCredentialSSHKeyFromAgent(username)
+				}
+				continue
+			}
+
+			if attempt-1 >= len(SSHKeyFiles) {
+				break
+			}
+
+			home, err := os.UserHomeDir()
+			if err != nil {
+				break
+			}
+			private := filepath.Join(home, ".ssh", SSHKeyFiles[attempt-1])
+			if _, err := os.Stat(private); err != nil {
+				continue
+			}
+
+			public := private + ".pub"
+			if _, err := os.Stat(public); err != nil {
+				public = ""
+			}
+			return git.NewCredentialSSHKey(username, public, private, "")
+		}
+	}
+
+	if allowed&git.CredentialTypeUserpassPlaintext != 0 && !c.helperTried {
+		c.helperTried = true
+
+		user, password, err := credentialFill(remoteURL, username)
+		if err != nil {
+			return nil, err
+		}
+		return git.NewCredentialUserpassPlaintext(user, password)
+	}
+
+	return nil, fmt.Errorf("%s: %w", remoteURL, errNoCredentials)
+}
+
+// credentialFill asks the configured git credential helper for the username
+// and password of the URL, without prompting on the terminal.
+func credentialFill(remoteURL string, username string) (string, string, error) {
+	u, err := url.Parse(remoteURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	var input bytes.Buffer
+	fmt.Fprintf(&input, "protocol=%s\nhost=%s\n", u.Scheme, u.Host)
+	if path := strings.TrimPrefix(u.Path, "/"); path != "" {
+		fmt.Fprintf(&input, "path=%s\n", path)
+	}
+	if u.User != nil && u.User.Username() != "" {
+		fmt.Fprintf(&input, "username=%s\n", u.User.Username())
+	}
+	input.WriteString("\n")
+
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Stdin = &input
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("git credential fill: %w", err)
+	}
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if parts := strings.SplitN(scanner.Text(), "=", 2); len(parts) == 2 {
+			values[parts[0]] = parts[1]
+		}
+	}
+
+	if values["username"] == "" {
+		values["username"] = username
+	}
+	return values["username"], values["password"], nil
+}
+
+// RemoteCallbacks returns the callbacks for fetching from and pushing to
+// remotes: credentials, and progress written to progress when it isn't nil.
+func RemoteCallbacks(progress io.Writer) git.RemoteCallbacks {
+	creds := new(credentials)
+	callbacks := git.RemoteCallbacks{CredentialsCallback: creds.callback}
+
+	if progress == nil {
+		return callbacks
+	}
+
+	callbacks.SidebandProgressCallback = func(str string) error {
+		_, err := io.WriteString(progress, "remote: "+str)
+		return err
+	}
+	callbacks.TransferProgressCallback = func(stats git.TransferProgress) error {
+		if stats.TotalObjects == 0 {
+			return nil
+		}
+		fmt.Fprintf(progress, "\rReceiving objects: %3d%% (%d/%d)",
+			stats.ReceivedObjects*100/stats.TotalObjects, stats.ReceivedObjects, stats.TotalObjects)
+		if stats.IndexedObjects == stats.TotalObjects {
+			fmt.Fprintln(progress, ", done.")
+		}
+		return nil
+	}
+	callbacks.UpdateTipsCallback = func(refname string, a *git.Oid, b *git.Oid) error {
+		switch {
+		case b == nil || b.IsZero():
+			fmt.Fprintf(progress, " - [deleted]  %s\n", refname)
+		case a == nil || a.IsZero():
+			fmt.Fprintf(progress, " * [new]      %s\n", refname)
+		default:
+			fmt.Fprintf(progress, "   %s..%s  %s\n", a.String()[:7], b.String()[:7], refname)
+		}
+		return nil
+	}
+
+	return callbacks
+}
+
+// BaseRemote is the remote of the base branch: the remote of a
+// remote-tracking base such as upstream/main, `branch.<base>.remote`, or
+// origin.
+func BaseRemote(repo *git.Repository, baseBranch string) string {
+	if branch, err := repo.LookupBranch(baseBranch, git.BranchRemote); err == nil {
+		if remote, err := repo.RemoteName(branch.Reference.Name()); err == nil {
+			return remote
+		}
+	}
+
+	config, err := repo.Config()
+	if err != nil {
+		return "origin"
+	}
+
+	remote, err := config.LookupString(fmt.Sprintf("branch.%s.remote", baseBranch))
+	if err != nil || remote == "" || remote == "." {
+		return "origin"
+	}
+	return remote
+}
+
+// Fetch fetches the configured refspecs of every remote, pruning the
+// remote-tracking branches that were deleted on the remote.
+func Fetch(repo *git.Repository, remotes []string, progress io.Writer) error {
+	for _, name := range remotes {
+		remote, err := repo.Remotes.Lookup(name)
+		if err != nil {
+			return fmt.Errorf("could not find remote '%s': %w", name, err)
+		}
+
+		if progress != nil {
+			fmt.Fprintf(progress, "Fetching %s\n", name)
+		}
+
+		opts := &git.FetchOptions{
+			RemoteCallbacks: RemoteCallbacks(progress),
+			Prune:           git.FetchPruneOn,
+			UpdateFetchhead: true,
+		}
+		err = remote.Fetch(nil, opts, "")
+		remote.Free()
+		if err != nil {
+			return fmt.Errorf("could not fetch '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// FetchRemotes returns the remotes to fetch for a value of Options.Fetch.
+func FetchRemotes(repo *git.Repository, fetch string, baseBranch string) ([]string, error) {
+	switch fetch {
+	case "":
+		return nil, nil
+	case FetchBase:
+		return []string{BaseRemote(repo, baseBranch)}, nil
+	case FetchAll:
+		remotes, err := repo.Remotes.List()
+		if err != nil {
+			return nil, fmt.Errorf("could not list remotes: %w", err)
+		}
+		return remotes, nil
+	}
+	return nil, fmt.Errorf("unknown fetch '%s', expected %s or %s", fetch, FetchBase, FetchAll)
+}
+
+// PushResult is the outcome of pushing one ref. Status is empty on success,
+// and the reason given by the remote when it was rejected.
+type PushResult struct {
+	Ref    string
+	Status string
+}
+
+// Push pushes the refspecs to the remote in a single push and returns the
+// result for each ref.
+func Push(repo *git.Repository, remoteName string, refspecs []string, progress io.Writer) ([]PushResult, error) {
+	remote, err := repo.Remotes.Lookup(remoteName)
+	if err != nil {
+		return nil, fmt.Errorf("could not find remote '%s': %w", remoteName, err)
+	}
+	defer remote.Free()
+
+	results := []PushResult{}
+	callbacks := RemoteCallbacks(progress)
+	callbacks.PushUpdateReferenceCallback = func(refname, status string) error {
+		results = append(results, PushResult{Ref: refname, Status: status})
+		return nil
+	}
+
+	err = remote.Push(refspecs, &git.PushOptions{RemoteCallbacks: callbacks})
+	if err != nil {
+		return results, fmt.Errorf("could not push to '%s': %w", remoteName, err)
+	}
+
+	return results, nil
+}
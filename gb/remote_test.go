@@ -0,0 +1,98 @@
+package gb
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+func (f *fixture) remoteTip(name string) string {
+	ref, err := f.repo.References.Lookup("refs/remotes/" + name)
+	if err != nil {
+		return ""
+	}
+	return ref.Target().String()
+}
+
+func TestFetch(t *testing.T) {
+	upstream := newBareFixture(t)
+	c1 := upstream.commit(nil, "c1", map[string]string{"a.txt": "1"})
+	upstream.branch("main", c1)
+	upstream.branch("gone", c1)
+
+	f := newFixture(t)
+	if _, err := f.repo.Remotes.Create("origin", upstream.dir); err != nil {
+		t.Fatal(err)
+	}
+
+	remotes, err := FetchRemotes(f.repo, FetchBase, "main")
+	if err != nil || len(remotes) != 1 || remotes[0] != "origin" {
+		t.Fatalf("got %v, %v, want origin", remotes, err)
+	}
+
+	var progress bytes.Buffer
+	if err := Fetch(f.repo, remotes, &progress); err != nil {
+		t.Fatal(err)
+	}
+	if f.remoteTip("origin/main") != c1.String() || f.remoteTip("origin/gone") != c1.String() {
+		t.Fatalf("fetch did not create origin/main and origin/gone")
+	}
+	if !strings.Contains(progress.String(), "Fetching origin") {
+		t.Errorf("no progress reported:\n%s", progress.String())
+	}
+
+	c2 := upstream.commit(c1, "c2", map[string]string{"a.txt": "2"})
+	upstream.branch("main", c2)
+	gone, err := upstream.repo.LookupBranch("gone", git.BranchLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gone.Delete(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Fetch(f.repo, remotes, nil); err != nil {
+		t.Fatal(err)
+	}
+	if f.remoteTip("origin/main") != c2.String() {
+		t.Errorf("fetch did not update origin/main")
+	}
+	if f.remoteTip("origin/gone") != "" {
+		t.Errorf("fetch did not prune origin/gone")
+	}
+}
+
+func TestFetchRemotes(t *testing.T) {
+	f := newFixture(t)
+	for _, name := range []string{"origin", "upstream"} {
+		if _, err := f.repo.Remotes.Create(name, "https://example.com/"+name+".git"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config, err := f.repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.SetString("branch.main.remote", "upstream")
+
+	if remotes, _ := FetchRemotes(f.repo, FetchBase, "main"); len(remotes) != 1 || remotes[0] != "upstream" {
+		t.Errorf("base: got %v, want upstream", remotes)
+	}
+	main := f.commit(nil, "c1", map[string]string{"a.txt": "1"})
+	if _, err := f.repo.References.Create("refs/remotes/upstream/main", main, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	config.SetString("branch.main.remote", "origin")
+	if remotes, _ := FetchRemotes(f.repo, FetchBase, "upstream/main"); len(remotes) != 1 || remotes[0] != "upstream" {
+		t.Errorf("remote base: got %v, want upstream", remotes)
+	}
+	if remotes, _ := FetchRemotes(f.repo, FetchAll, "main"); len(remotes) != 2 {
+		t.Errorf("all: got %v, want origin and upstream", remotes)
+	}
+	if _, err := FetchRemotes(f.repo, "some", "main"); err == nil {
+		t.Error("expected an error for an unknown value")
+	}
+}
@@ -0,0 +1,270 @@
+package gb
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// Rename is a branch to rename or copy.
+type Rename struct {
+	Old string
+	New string
+}
+
+// branchConfig returns the `branch.<name>.*` entries of the repository's own
+// config, leaving out the global ones.
+func branchConfig(config *git.Config, branchName string) ([]*git.ConfigEntry, error) {
+	pattern := "^branch\\." + regexp.QuoteMeta(branchName) + "\\.[^.]+$"
+
+	iterator, err := config.NewIteratorGlob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the config of '%s': %w", branchName, err)
+	}
+	defer iterator.Free()
+
+	entries := []*git.ConfigEntry{}
+	for {
+		entry, err := iterator.Next()
+		if git.IsErrorCode(err, git.ErrorCodeIterOver) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read the config of '%s': %w", branchName, err)
+		}
+		if entry.Level == git.ConfigLevelLocal {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// CopyBranchConfig copies every `branch.<old>.*` key, such as the upstream,
+// the description and the stack parent, to `branch.<new>.*`. The old keys are
+// deleted when move is set.
+func CopyBranchConfig(repo *git.Repository, oldName string, newName string, move bool) error {
+	config, err := repo.Config()
+	if err != nil {
+		return err
+	}
+
+	entries, err := branchConfig(config, oldName)
+	if err != nil {
+		return err
+	}
+
+	oldPrefix := "branch." + oldName + "."
+	set := make(map[string]bool)
+
+	for _, entry := range entries {
+		name := "branch." + newName + "." + strings.TrimPrefix(entry.Name, oldPrefix)
+
+		if !set[name] {
+			err = config.SetString(name, entry.Value)
+			set[name] = true
+		} else {
+			// A multi-valued key: the pattern matches no value, so the
+			// value is added.
+			err = config.SetMultivar(name, "^a^", entry.Value)
+		}
+		if err != nil {
+			return fmt.Errorf("could not set %s: %w", name, err)
+		}
+	}
+
+	if !move {
+		return nil
+	}
+
+	for _, entry := range entries {
+		if err := config.Delete(entry.Name); err != nil {
+			return fmt.Errorf("could not delete %s: %w", entry.Name, err)
+		}
+	}
+	return nil
+}
+
+// RenameBranch renames the local branch, or copies it when copy is set, along
+// with its `branch.<name>.*` config. On a rename, branches stacked on the old
+// name are stacked on the new one and its cached pull request is dropped.
+func RenameBranch(repo *git.Repository, oldName string, newName string, copy bool) (*git.Branch, error) {
+	branch, err := repo.LookupBranch(oldName, git.BranchLocal)
+	if err != nil {
+		return nil, fmt.Errorf("could not find branch '%s': %w", oldName, err)
+	}
+
+	if _, err := repo.LookupBranch(newName, git.BranchLocal); err == nil {
+		return nil, fmt.Errorf("branch '%s' already exists", newName)
+	}
+
+	if copy {
+		commit, err := repo.LookupCommit(branch.Target())
+		if err != nil {
+			return nil, fmt.Errorf("could not lookup commit of '%s': %w", oldName, err)
+		}
+
+		renamed, err := repo.CreateBranch(newName, commit, false)
+		if err != nil {
+			return nil, fmt.Errorf("could not create branch '%s': %w", newName, err)
+		}
+
+		return renamed, CopyBranchConfig(repo, oldName, newName, false)
+	}
+
+	// Moving the branch also moves HEAD and the branch's config section;
+	// CopyBranchConfig takes care of anything left behind.
+	renamed, err := branch.Move(newName, false)
+	if err != nil {
+		return nil, fmt.Errorf("could not rename '%s' to '%s': %w", oldName, newName, err)
+	}
+
+	if err := CopyBranchConfig(repo, oldName, newName, true); err != nil {
+		return renamed, err
+	}
+
+	if err := restackRenamed(repo, oldName, newName); err != nil {
+		return renamed, err
+	}
+
+	cachePath := ProviderCachePath(repo)
+	cache := NewProviderCache(cachePath)
+	for key := range cache {
+		if strings.HasSuffix(key, " pr "+oldName) {
+			delete(cache, key)
+		}
+	}
+	return renamed, cache.WriteToFile(cachePath)
+}
+
+// restackRenamed points the recorded stack parents of other branches at the
+// new name.
+func restackRenamed(repo *git.Repository, oldName string, newName string) error {
+	config, err := repo.Config()
+	if err != nil {
+		return err
+	}
+
+	iterator, err := config.NewIteratorGlob("^branch\\..*\\.gbparent$")
+	if err != nil {
+		return err
+	}
+	defer iterator.Free()
+
+	keys := []string{}
+	for {
+		entry, err := iterator.Next()
+		if git.IsErrorCode(err, git.ErrorCodeIterOver) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if entry.Value == oldName {
+			keys = append(keys, entry.Name)
+		}
+	}
+
+	for _, key := range keys {
+		if err := config.SetString(key, newName); err != nil {
+			return fmt.Errorf("could not set %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// PlanRenames returns the local branches whose whole name matches the
+// pattern, with their new name from the replacement, where $1 stands for the
+// first group. It fails when a new name is taken or given twice.
+func PlanRenames(repo *git.Repository, pattern *regexp.Regexp, replacement string) ([]Rename, error) {
+	anchored, err := regexp.Compile("^(?:" + pattern.String() + ")$")
+	if err != nil {
+		return nil, err
+	}
+
+	branch_iterator, err := repo.NewBranchIterator(git.BranchLocal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches for '%s': %w", repo.Workdir(), err)
+	}
+	defer branch_iterator.Free()
+
+	existing := make(map[string]bool)
+	renames := []Rename{}
+
+	err = branch_iterator.ForEach(func(branch *git.Branch, _ git.BranchType) error {
+		name, err := branch.Name()
+		if err != nil {
+			return err
+		}
+		existing[name] = true
+
+		if anchored.MatchString(name) {
+			newName := anchored.ReplaceAllString(name, replacement)
+			if newName != name {
+				renames = append(renames, Rename{Old: name, New: newName})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+
+	taken := make(map[string]string)
+	for _, rename := range renames {
+		if existing[rename.New] {
+			return nil, fmt.Errorf("cannot rename '%s': branch '%s' already exists", rename.Old, rename.New)
+		}
+		if other, ok := taken[rename.New]; ok {
+			return nil, fmt.Errorf("both '%s' and '%s' would be renamed to '%s'", other, rename.Old, rename.New)
+		}
+		taken[rename.New] = rename.Old
+	}
+
+	return renames, nil
+}
+
+// PushRename pushes the renamed or copied branch to the remote of its
+// upstream, or origin, and sets its upstream there. With deleteOld, the old
+// upstream branch is deleted in the same push.
+func PushRename(repo *git.Repository, branch *git.Branch, deleteOld bool, progress io.Writer) ([]PushResult, error) {
+	name, err := branch.Name()
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	remoteName, err := config.LookupString(fmt.Sprintf("branch.%s.remote", name))
+	if err != nil || remoteName == "" || remoteName == "." {
+		remoteName = "origin"
+	}
+	oldMerge, _ := config.LookupString(fmt.Sprintf("branch.%s.merge", name))
+
+	ref := "refs/heads/" + name
+	refspecs := []string{ref + ":" + ref}
+	if deleteOld && oldMerge != "" && oldMerge != ref {
+		refspecs = append(refspecs, ":"+oldMerge)
+	}
+
+	results, err := Push(repo, remoteName, refspecs, progress)
+	if err != nil {
+		return results, err
+	}
+
+	for _, result := range results {
+		if result.Ref == ref && result.Status != "" {
+			return results, nil
+		}
+	}
+
+	if err := branch.SetUpstream(remoteName + "/" + name); err != nil {
+		return results, fmt.Errorf("could not set the upstream of '%s': %w", name, err)
+	}
+	return results, nil
+}
@@ -0,0 +1,134 @@
+package gb
+
+import (
+	"regexp"
+	"testing"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+func (f *fixture) config() *git.Config {
+	config, err := f.repo.Config()
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return config
+}
+
+func TestRenameBranch(t *testing.T) {
+	f := newListFixture(t)
+	f.checkout("feature")
+
+	config := f.config()
+	config.SetString(DescriptionKey("feature"), "the feature")
+	config.SetString(StackParentKey("merged"), "feature")
+
+	if _, err := RenameBranch(f.repo, "feature", "feature-2", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.repo.LookupBranch("feature", git.BranchLocal); err == nil {
+		t.Error("feature still exists")
+	}
+	if got := BranchDescription(f.repo, "feature-2"); got != "the feature" {
+		t.Errorf("description: got %q", got)
+	}
+	if got := BranchDescription(f.repo, "feature"); got != "" {
+		t.Errorf("old description left behind: %q", got)
+	}
+	if got, _ := config.LookupString(StackParentKey("merged")); got != "feature-2" {
+		t.Errorf("stack parent: got %q, want feature-2", got)
+	}
+
+	head, err := f.repo.Head()
+	if err != nil || head.Name() != "refs/heads/feature-2" {
+		t.Errorf("HEAD did not follow the rename: %v", err)
+	}
+
+	if _, err := RenameBranch(f.repo, "feature-2", "main", false); err == nil {
+		t.Error("expected an error when the new name is taken")
+	}
+}
+
+func TestCopyBranch(t *testing.T) {
+	f := newListFixture(t)
+	f.config().SetString(DescriptionKey("feature"), "the feature")
+
+	if _, err := RenameBranch(f.repo, "feature", "feature-copy", true); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"feature", "feature-copy"} {
+		if got := BranchDescription(f.repo, name); got != "the feature" {
+			t.Errorf("%s: got description %q", name, got)
+		}
+	}
+}
+
+func TestPlanRenames(t *testing.T) {
+	f := newListFixture(t)
+	tip := mustTarget(t, f, "main")
+	f.branch("feat/a", tip)
+	f.branch("feat/b", tip)
+	f.branch("myfeat/c", tip)
+
+	renames, err := PlanRenames(f.repo, regexp.MustCompile(`feat/(.*)`), "feature/$1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(renames) != 2 || renames[0] != (Rename{"feat/a", "feature/a"}) || renames[1] != (Rename{"feat/b", "feature/b"}) {
+		t.Errorf("got %v", renames)
+	}
+
+	if _, err := PlanRenames(f.repo, regexp.MustCompile(`feat/.*`), "feature"); err == nil {
+		t.Error("expected an error when two branches get the same name")
+	}
+}
+
+func mustTarget(t *testing.T, f *fixture, name string) *git.Oid {
+	branch, err := f.repo.LookupBranch(name, git.BranchLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return branch.Target()
+}
+
+func TestPushRename(t *testing.T) {
+	upstream := newBareFixture(t)
+	c1 := upstream.commit(nil, "c1", map[string]string{"a.txt": "1"})
+	upstream.branch("feat/x", c1)
+
+	f := newFixture(t)
+	if _, err := f.repo.Remotes.Create("origin", upstream.dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := Fetch(f.repo, []string{"origin"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Track(f.repo, "origin/feat/x", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	branch, err := RenameBranch(f.repo, "feat/x", "feature/x", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := PushRename(f.repo, branch, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Errorf("got %+v, want the new name pushed and the old one deleted", results)
+	}
+
+	if _, err := upstream.repo.LookupBranch("feature/x", git.BranchLocal); err != nil {
+		t.Error("feature/x was not pushed")
+	}
+	if _, err := upstream.repo.LookupBranch("feat/x", git.BranchLocal); err == nil {
+		t.Error("feat/x was not deleted upstream")
+	}
+	if name, err := f.repo.UpstreamName("refs/heads/feature/x"); err != nil || name != "refs/remotes/origin/feature/x" {
+		t.Errorf("upstream: got %q, %v", name, err)
+	}
+}
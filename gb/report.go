@@ -0,0 +1,283 @@
+package gb
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// ReportCommitLimit is how many unique commits a report lists per branch.
+const ReportCommitLimit = 20
+
+// ReportBarWidth is the length of the longest ahead/behind bar in a Markdown
+// report.
+const ReportBarWidth = 20
+
+// Report summarizes the branches of a repository for a periodic review.
+type Report struct {
+	Repo      string
+	Base      string
+	Generated time.Time
+
+	// Total, Merged and Stale count the branches other than the base.
+	Total  int
+	Merged int
+	Stale  int
+
+	Authors  []AuthorCount
+	Branches []ReportBranch
+
+	// MaxCount is the largest ahead or behind count, the scale of the bars.
+	MaxCount int
+}
+
+// AuthorCount is the number of branches whose last commit is by Name.
+type AuthorCount struct {
+	Name     string
+	Branches int
+}
+
+// ReportBranch is a branch of the report with the commits that are not in the
+// base.
+type ReportBranch struct {
+	BranchStatus
+
+	Commits      []CommitSummary
+	TotalCommits int
+}
+
+// MoreCommits is the number of unique commits left out of Commits.
+func (b ReportBranch) MoreCommits() int {
+	return b.TotalCommits - len(b.Commits)
+}
+
+// NewReport builds the report of the statuses returned by List.
+func NewReport(repo *git.Repository, baseBranch string, statuses []BranchStatus) (*Report, error) {
+	base_oid, err := LookupBaseOid(repo, baseBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{
+		Repo:      RepoPath(repo),
+		Base:      baseBranch,
+		Generated: time.Now(),
+	}
+
+	authors := make(map[string]int)
+
+	for _, status := range statuses {
+		branch := ReportBranch{BranchStatus: status}
+
+		if !status.IsBase {
+			r.Total++
+			if status.IsMerged {
+				r.Merged++
+			}
+			if status.IsStale {
+				r.Stale++
+			}
+			if status.Author != "" {
+				authors[status.Author]++
+			}
+		}
+
+		if status.Err == nil && !status.IsBase && !status.IsMerged {
+			oid, err := git.NewOid(status.Oid)
+			if err != nil {
+				return nil, err
+			}
+
+			branch.Commits, branch.TotalCommits, err = UniqueCommits(repo, base_oid, oid, ReportCommitLimit)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if status.Ahead > r.MaxCount {
+			r.MaxCount = status.Ahead
+		}
+		if status.Behind > r.MaxCount {
+			r.MaxCount = status.Behind
+		}
+
+		r.Branches = append(r.Branches, branch)
+	}
+
+	for name, count := range authors {
+		r.Authors = append(r.Authors, AuthorCount{Name: name, Branches: count})
+	}
+	sort.Slice(r.Authors, func(i, j int) bool {
+		if r.Authors[i].Branches != r.Authors[j].Branches {
+			return r.Authors[i].Branches > r.Authors[j].Branches
+		}
+		return r.Authors[i].Name < r.Authors[j].Name
+	})
+
+	return r, nil
+}
+
+// percent scales n against the largest count of the report.
+func (r *Report) percent(n int) int {
+	if r.MaxCount == 0 || n <= 0 {
+		return 0
+	}
+	return n * 100 / r.MaxCount
+}
+
+// bar draws n as a line of blocks, at least one block for any commit.
+func (r *Report) bar(n int) string {
+	if r.MaxCount == 0 || n <= 0 {
+		return ""
+	}
+
+	width := n * ReportBarWidth / r.MaxCount
+	if width == 0 {
+		width = 1
+	}
+	return strings.Repeat("█", width)
+}
+
+var reportColors = map[string]string{
+	"green":  "#1a7f37",
+	"red":    "#cf222e",
+	"yellow": "#9a6700",
+}
+
+var reportDots = map[string]string{
+	"green":  "🟢",
+	"red":    "🔴",
+	"yellow": "🟡",
+}
+
+func (r *Report) funcs() map[string]interface{} {
+	return map[string]interface{}{
+		"percent": r.percent,
+		"bar":     r.bar,
+		"short":   func(oid string) string { return oid[:7] },
+		"date":    func(t time.Time) string { return t.Format("2006-01-02") },
+		"color":   func(s BranchStatus) string { return reportColors[s.Color()] },
+		"dot":     func(s BranchStatus) string { return reportDots[s.Color()] },
+		"cell":    markdownCell,
+	}
+}
+
+// markdownCell escapes the text for a Markdown table cell: pipes would end
+// the cell and newlines the row.
+func markdownCell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.Replace(s, "|", `\|`, -1)
+}
+
+// WriteHTML writes the report as a self-contained HTML document.
+func (r *Report) WriteHTML(w io.Writer) error {
+	t, err := htmltemplate.New("report").Funcs(r.funcs()).Parse(reportHTML)
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, r)
+}
+
+// WriteMarkdown writes the report as a Markdown document.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	t, err := texttemplate.New("report").Funcs(r.funcs()).Parse(reportMarkdown)
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, r)
+}
+
+const reportHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Branches of {{.Repo}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #1f2328; }
+table { border-collapse: collapse; }
+th, td { padding: 4px 8px; text-align: left; vertical-align: top; border-bottom: 1px solid #d0d7de; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+.bar { height: 10px; }
+.behind { background: #cf222e; margin-left: auto; }
+.ahead { background: #1a7f37; }
+.barcell { width: 120px; }
+code, .oid { font-family: ui-monospace, Menlo, monospace; font-size: 90%; }
+summary { cursor: pointer; }
+</style>
+</head>
+<body>
+<h1>Branches of {{.Repo}}</h1>
+<p>Compared against <strong>{{.Base}}</strong> on {{date .Generated}}.</p>
+
+<h2>Summary</h2>
+<table>
+<tr><th>Branches</th><td class="num">{{.Total}}</td></tr>
+<tr><th>Merged</th><td class="num">{{.Merged}}</td></tr>
+<tr><th>Stale</th><td class="num">{{.Stale}}</td></tr>
+</table>
+
+{{if .Authors}}<h3>By author</h3>
+<table>
+{{range .Authors}}<tr><td>{{.Name}}</td><td class="num">{{.Branches}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Branches</h2>
+<table>
+<tr><th>Last commit</th><th>Branch</th><th>Author</th><th colspan="2">Behind</th><th colspan="2">Ahead</th><th></th></tr>
+{{range .Branches}}<tr>
+<td>{{if not .When.IsZero}}{{date .When}}{{end}}</td>
+<td style="color: {{color .BranchStatus}}">{{if .IsBase}}<strong>{{.Name}}</strong>{{else}}{{.Name}}{{end}}
+{{if .Err}}<div>error: {{.Err.Err}}</div>{{end}}
+{{if .Commits}}<details><summary>{{.TotalCommits}} unique commits</summary>
+<ul>
+{{range .Commits}}<li><span class="oid">{{short .Oid}}</span> {{.Summary}} <em>{{.Author}}</em></li>
+{{end}}{{if .MoreCommits}}<li>and {{.MoreCommits}} more</li>
+{{end}}</ul>
+</details>{{end}}</td>
+<td>{{.Author}}</td>
+{{if .IsBase}}<td colspan="4"></td>{{else}}<td class="barcell"><div class="bar behind" style="width: {{percent .Behind}}%"></div></td>
+<td class="num">{{.Behind}}</td>
+<td class="barcell"><div class="bar ahead" style="width: {{percent .Ahead}}%"></div></td>
+<td class="num">{{.Ahead}}</td>{{end}}
+<td>{{if .IsBase}}base{{else if .IsMerged}}merged{{else if .IsStale}}stale{{end}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`
+
+const reportMarkdown = `# Branches of {{.Repo}}
+
+Compared against **{{.Base}}** on {{date .Generated}}.
+
+## Summary
+
+| | Branches |
+|---|---:|
+| Total | {{.Total}} |
+| Merged | {{.Merged}} |
+| Stale | {{.Stale}} |
+{{if .Authors}}
+| Author | Branches |
+|---|---:|
+{{range .Authors}}| {{cell .Name}} | {{.Branches}} |
+{{end}}{{end}}
+## Branches
+
+| | Last commit | Branch | Author | Behind | | Ahead | | |
+|---|---|---|---|---:|---|---:|---|---|
+{{range .Branches}}| {{dot .BranchStatus}} | {{if not .When.IsZero}}{{date .When}}{{end}} | {{if .IsBase}}**{{cell .Name}}**{{else}}{{cell .Name}}{{end}} | {{cell .Author}} | {{if not .IsBase}}{{.Behind}} | {{bar .Behind}} | {{.Ahead}} | {{bar .Ahead}}{{else}} | | | {{end}} | {{if .Err}}error: {{cell .Err.Err.Error}}{{else if .IsBase}}base{{else if .IsMerged}}merged{{else if .IsStale}}stale{{end}} |
+{{end}}{{range .Branches}}{{if .Commits}}
+<details>
+<summary>{{cell .Name}}: {{.TotalCommits}} unique commits</summary>
+
+{{range .Commits}}- ` + "`{{short .Oid}}`" + ` {{cell .Summary}} ({{cell .Author}})
+{{end}}{{if .MoreCommits}}- and {{.MoreCommits}} more
+{{end}}
+</details>
+{{end}}{{end}}`
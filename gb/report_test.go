@@ -0,0 +1,64 @@
+package gb
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	f := newListFixture(t)
+
+	statuses, err := List(context.Background(), f.repo, f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReport(f.repo, "main", statuses)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Total != 2 || r.Merged != 1 || r.Stale != 0 {
+		t.Errorf("got total %d, merged %d, stale %d, want 2 1 0", r.Total, r.Merged, r.Stale)
+	}
+	if len(r.Authors) != 1 || r.Authors[0].Branches != 2 {
+		t.Errorf("got authors %+v, want gb with 2 branches", r.Authors)
+	}
+
+	for _, branch := range r.Branches {
+		want := 0
+		if branch.Name == "feature" {
+			want = 2
+		}
+		if branch.TotalCommits != want || len(branch.Commits) != want {
+			t.Errorf("%s: got %d unique commits, want %d", branch.Name, branch.TotalCommits, want)
+		}
+	}
+
+	var html bytes.Buffer
+	if err := r.WriteHTML(&html); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), "<details><summary>2 unique commits</summary>") {
+		t.Errorf("HTML report is missing the unique commits of feature:\n%s", html.String())
+	}
+	if strings.Contains(html.String(), "http") {
+		t.Errorf("HTML report references external assets")
+	}
+
+	var md bytes.Buffer
+	if err := r.WriteMarkdown(&md); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(md.String(), "| feature | gb | 1 | ") {
+		t.Errorf("Markdown report is missing the feature row:\n%s", md.String())
+	}
+}
+
+func TestMarkdownCell(t *testing.T) {
+	if got, want := markdownCell("fix a | b\r\nand more\n"), `fix a \| b and more`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,250 @@
+package gb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// Server serves a read-only dashboard and JSON API for the branches of a
+// repository:
+//
+//	/                       the HTML report
+//	/api/branches           every branch, as written by WriteJSON
+//	/api/branches/{name}    a single branch
+//	/metrics                the metrics of WriteMetrics
+//
+// Every route takes a base query parameter. Responses other than metrics,
+// whose ages change with time, carry an ETag derived from the refs of the
+// repository, so polling clients get 304 Not Modified until a ref moves.
+type Server struct {
+	Repo *git.Repository
+
+	// mutex serializes requests: they share the repository and cache file.
+	mutex sync.Mutex
+}
+
+func NewServer(repo *git.Repository) *Server {
+	return &Server{Repo: repo}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	baseBranch := BaseBranch(s.Repo, r.URL.Query().Get("base"))
+
+	if r.URL.Path == "/metrics" {
+		s.serveMetrics(w, r, baseBranch)
+		return
+	}
+
+	etag, err := RefsETag(s.Repo, r.URL.Path+"?base="+baseBranch)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var buf bytes.Buffer
+	contentType, complete, err := s.render(r.Context(), &buf, r.URL.Path, baseBranch)
+	if err != nil {
+		http.Error(w, err.Error(), httpStatus(err))
+		return
+	}
+
+	// Branches that failed may succeed on the next request, so a response
+	// with errors is never cached.
+	if complete {
+		w.Header().Set("ETag", etag)
+	}
+	w.Header().Set("Content-Type", contentType)
+	buf.WriteTo(w)
+}
+
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request, baseBranch string) {
+	opts := DefaultOptions()
+	opts.Base = baseBranch
+	opts.Flat = true
+
+	comparisons, err := Compare(r.Context(), s.Repo, opts)
+	var partial *PartialError
+	if err != nil && !errors.As(err, &partial) {
+		http.Error(w, err.Error(), httpStatus(err))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := WriteMetrics(&buf, RepoPath(s.Repo), baseBranch, comparisons, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", MetricsContentType)
+	buf.WriteTo(w)
+}
+
+// errNotFound is returned by render for unknown paths and branches.
+var errNotFound = errors.New("not found")
+
+func httpStatus(err error) int {
+	if errors.Is(err, errNotFound) || errors.Is(err, ErrBaseNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// render writes the response for the path to buf and returns its content
+// type. It reports whether every branch could be compared.
+func (s *Server) render(ctx context.Context, buf *bytes.Buffer, path string, baseBranch string) (string, bool, error) {
+	name := ""
+	switch {
+	case path == "/" || path == "/api/branches":
+	case strings.HasPrefix(path, "/api/branches/"):
+		name = strings.TrimPrefix(path, "/api/branches/")
+	default:
+		return "", false, errNotFound
+	}
+
+	opts := DefaultOptions()
+	opts.Base = baseBranch
+
+	statuses, err := List(ctx, s.Repo, opts)
+	var partial *PartialError
+	if err != nil && !errors.As(err, &partial) {
+		return "", false, err
+	}
+	complete := err == nil
+
+	if path == "/" {
+		report, err := NewReport(s.Repo, baseBranch, statuses)
+		if err != nil {
+			return "", false, err
+		}
+		return "text/html; charset=utf-8", complete, report.WriteHTML(buf)
+	}
+
+	if name == "" {
+		return "application/json", complete, WriteJSON(buf, Columns, statuses)
+	}
+
+	for _, status := range statuses {
+		if status.Name == name {
+			return "application/json", complete, status.WriteJSON(buf, Columns)
+		}
+	}
+	return "", false, fmt.Errorf("%w: branch '%s'", errNotFound, name)
+}
+
+// RefsETag hashes the name and target of every ref of the repository, HEAD
+// included, along with the given key. Whether each branch is stale and the
+// `branch.*` config, which holds the descriptions, are hashed too.
+func RefsETag(repo *git.Repository, key string) (string, error) {
+	iterator, err := repo.NewReferenceIterator()
+	if err != nil {
+		return "", fmt.Errorf("failed to list references: %w", err)
+	}
+	defer iterator.Free()
+
+	refs := []string{}
+	for {
+		ref, err := iterator.Next()
+		if git.IsErrorCode(err, git.ErrorCodeIterOver) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to list references: %w", err)
+		}
+		state := refState(ref)
+		if ref.IsBranch() && ref.Type() == git.ReferenceOid && staleTip(repo, ref.Target()) {
+			state += " stale"
+		}
+		refs = append(refs, state)
+	}
+	sort.Strings(refs)
+
+	if head, err := repo.References.Lookup("HEAD"); err == nil {
+		refs = append(refs, refState(head))
+	}
+
+	config, err := branchConfigState(repo)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha1.New()
+	fmt.Fprintln(hash, key)
+	for _, ref := range refs {
+		fmt.Fprintln(hash, ref)
+	}
+	for _, entry := range config {
+		fmt.Fprintln(hash, entry)
+	}
+	return fmt.Sprintf(`"%x"`, hash.Sum(nil)), nil
+}
+
+// branchConfigState lists every `branch.*` config entry as "name=value".
+func branchConfigState(repo *git.Repository) ([]string, error) {
+	config, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := config.NewIteratorGlob("^branch\\.")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the branch config: %w", err)
+	}
+	defer iterator.Free()
+
+	entries := []string{}
+	for {
+		entry, err := iterator.Next()
+		if git.IsErrorCode(err, git.ErrorCodeIterOver) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the branch config: %w", err)
+		}
+		entries = append(entries, entry.Name+"="+entry.Value)
+	}
+	return entries, nil
+}
+
+// staleTip reports whether the commit is older than StaleAfter.
+func staleTip(repo *git.Repository, oid *git.Oid) bool {
+	commit, err := repo.LookupCommit(oid)
+	if err != nil {
+		return false
+	}
+	defer commit.Free()
+
+	return commit.Committer().When.Before(time.Now().Add(-StaleAfter))
+}
+
+func refState(ref *git.Reference) string {
+	if ref.Type() == git.ReferenceSymbolic {
+		return ref.Name() + " " + ref.SymbolicTarget()
+	}
+	return ref.Name() + " " + ref.Target().String()
+}
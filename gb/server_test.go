@@ -0,0 +1,95 @@
+package gb
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func get(t *testing.T, handler http.Handler, path string, etag string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServerBranches(t *testing.T) {
+	f := newListFixture(t)
+	server := NewServer(f.repo)
+
+	rec := get(t, server, "/api/branches?base=main", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	branches := []map[string]interface{}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &branches); err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 3 {
+		t.Errorf("got %d branches, want 3", len(branches))
+	}
+
+	rec = get(t, server, "/api/branches/feature?base=main", "")
+	branch := map[string]interface{}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &branch); err != nil {
+		t.Fatal(err)
+	}
+	if branch["name"] != "feature" || branch["ahead"] != 2.0 {
+		t.Errorf("got %v, want feature 2 ahead", branch)
+	}
+
+	if rec := get(t, server, "/api/branches/nope?base=main", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown branch: got %d, want 404", rec.Code)
+	}
+	if rec := get(t, server, "/api/branches?base=nope", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown base: got %d, want 404", rec.Code)
+	}
+	if rec := get(t, server, "/?base=main", ""); rec.Code != http.StatusOK {
+		t.Errorf("dashboard: got %d, want 200", rec.Code)
+	}
+
+	rec = get(t, server, "/metrics?base=main", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != MetricsContentType {
+		t.Errorf("metrics: got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestServerETag(t *testing.T) {
+	f := newListFixture(t)
+	server := NewServer(f.repo)
+
+	etag := get(t, server, "/api/branches?base=main", "").Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag")
+	}
+
+	if rec := get(t, server, "/api/branches?base=main", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("unchanged refs: got %d, want 304", rec.Code)
+	}
+
+	f.branch("another", f.commit(nil, "another", map[string]string{"c.txt": "1"}))
+
+	rec := get(t, server, "/api/branches?base=main", etag)
+	if rec.Code != http.StatusOK {
+		t.Errorf("new branch: got %d, want 200", rec.Code)
+	}
+	etag = rec.Header().Get("ETag")
+
+	if err := SetBranchDescription(f.repo, "feature", "Reworded"); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := get(t, server, "/api/branches?base=main", etag); rec.Code != http.StatusOK {
+		t.Errorf("new description: got %d, want 200", rec.Code)
+	}
+
+	if rec := get(t, server, "/api/branches?base=does-not-exist", ""); rec.Header().Get("ETag") != "" {
+		t.Errorf("error response: got ETag %q, want none", rec.Header().Get("ETag"))
+	}
+}
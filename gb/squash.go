@@ -0,0 +1,36 @@
+package gb
+
+import (
+	"fmt"
+)
+
+// IsSquashMerged reports whether the changes of an unmerged branch are already
+// in the base, as left by a squash or rebase merge: merging the branch into the
+// base would not change the base's tree.
+func (c *Comparison) IsSquashMerged() (bool, error) {
+	if c.IsMerged || c.Ahead == 0 {
+		return false, nil
+	}
+
+	baseCommit, err := c.Repo.LookupCommit(c.BaseOid)
+	if err != nil {
+		return false, fmt.Errorf("could not lookup commit '%s': %w", c.BaseOid.String(), err)
+	}
+
+	index, err := c.Repo.MergeCommits(baseCommit, c.Commit(), nil)
+	if err != nil {
+		return false, fmt.Errorf("could not merge '%s' into '%s': %w", c.Name(), c.BaseOid.String(), err)
+	}
+	defer index.Free()
+
+	if index.HasConflicts() {
+		return false, nil
+	}
+
+	tree_oid, err := index.WriteTreeTo(c.Repo)
+	if err != nil {
+		return false, fmt.Errorf("could not write merged tree of '%s': %w", c.Name(), err)
+	}
+
+	return tree_oid.Equal(baseCommit.TreeId()), nil
+}
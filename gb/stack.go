@@ -0,0 +1,341 @@
+package gb
+
+import (
+	"fmt"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+func StackParentKey(branchName string) string {
+	return fmt.Sprintf("branch.%s.gbparent", branchName)
+}
+
+func StackParentOidKey(branchName string) string {
+	return fmt.Sprintf("branch.%s.gbparentoid", branchName)
+}
+
+// MaxReflogTips is how many earlier tips of a branch, from its reflog, are
+// tried as the base of a branch stacked on it.
+const MaxReflogTips = 20
+
+// DetectStacks sets the Parent of every branch whose nearest ancestor among
+// the other unmerged branches is that branch's tip, and the ahead/behind
+// counts relative to that parent. Comparisons must be executed first; those
+// that failed are left out, and failures are recorded in Err.
+func (cs Comparisons) DetectStacks(baseBranch string) {
+	tips := make(map[*Comparison][]*git.Oid)
+
+	for _, child := range cs {
+		if child.Err != nil || child.IsBase(baseBranch) || child.IsMerged {
+			continue
+		}
+
+		if err := cs.detectParent(child, baseBranch, tips); err != nil {
+			child.Parent = nil
+			child.fail(err)
+		}
+	}
+}
+
+func (cs Comparisons) detectParent(child *Comparison, baseBranch string, tips map[*Comparison][]*git.Oid) error {
+	for _, candidate := range cs {
+		stacked, err := child.IsStackedOn(candidate, baseBranch)
+		if err != nil {
+			return err
+		}
+		if !stacked {
+			continue
+		}
+
+		// Keep the candidate closest to the child: a later candidate that
+		// descends from the current parent sits between it and the child.
+		closer := child.Parent == nil
+		if !closer {
+			closer, err = descendantOf(candidate, child.Parent)
+			if err != nil {
+				return err
+			}
+		}
+		if closer {
+			child.Parent = candidate
+		}
+	}
+
+	if child.Parent != nil {
+		child.ParentBaseOid = child.Parent.Oid
+	} else {
+		child.Parent, child.ParentBaseOid = cs.recordedParent(child, baseBranch)
+	}
+
+	if child.Parent == nil {
+		child.Parent, child.ParentBaseOid = cs.reflogParent(child, baseBranch, tips)
+	}
+
+	if child.Parent == nil {
+		return nil
+	}
+
+	var err error
+	child.ParentAhead, child.ParentBehind, err = child.Repo.AheadBehind(child.Oid, child.Parent.Oid)
+	if err != nil {
+		return fmt.Errorf("error getting ahead/behind relative to '%s': %w", child.Parent.Name(), err)
+	}
+
+	return nil
+}
+
+// recordedParent returns the parent saved by RecordStacks, along with the tip
+// the parent had at the time, as long as the child still contains that tip.
+// This keeps a stack together after its parent branch was amended or rebased.
+func (cs Comparisons) recordedParent(child *Comparison, baseBranch string) (*Comparison, *git.Oid) {
+	config, err := child.Repo.Config()
+	if err != nil {
+		return nil, nil
+	}
+
+	parentName, err := config.LookupString(StackParentKey(child.Name()))
+	if err != nil {
+		return nil, nil
+	}
+
+	parentOidString, err := config.LookupString(StackParentOidKey(child.Name()))
+	if err != nil {
+		return nil, nil
+	}
+
+	parentOid, err := git.NewOid(parentOidString)
+	if err != nil {
+		return nil, nil
+	}
+
+	for _, candidate := range cs {
+		if candidate.Name() != parentName || candidate == child {
+			continue
+		}
+
+		if candidate.Err != nil || candidate.IsMerged || candidate.IsBase(baseBranch) {
+			return nil, nil
+		}
+
+		if !parentOid.Equal(child.Oid) {
+			contained, err := child.Repo.DescendantOf(child.Oid, parentOid)
+			if err != nil || !contained {
+				return nil, nil
+			}
+		}
+
+		return candidate, parentOid
+	}
+
+	return nil, nil
+}
+
+// reflogParent returns the branch that had, before it was amended or rebased,
+// a tip the child still contains, along with that tip. This finds a stack
+// that was never recorded once its parent moved. tips caches the earlier tips
+// of each candidate.
+func (cs Comparisons) reflogParent(child *Comparison, baseBranch string, tips map[*Comparison][]*git.Oid) (*Comparison, *git.Oid) {
+	var parent *Comparison
+	var parentOid *git.Oid
+
+	for _, candidate := range cs {
+		if candidate == child || candidate.Err != nil || candidate.IsMerged || candidate.IsBase(baseBranch) {
+			continue
+		}
+
+		if _, ok := tips[candidate]; !ok {
+			tips[candidate] = candidate.reflogTips()
+		}
+
+		for _, tip := range tips[candidate] {
+			if tip.Equal(child.Oid) {
+				continue
+			}
+			contained, err := child.Repo.DescendantOf(child.Oid, tip)
+			if err != nil || !contained {
+				continue
+			}
+
+			// A candidate that since took in the child's commits sits on
+			// top of it, not below.
+			if above, _ := child.Repo.DescendantOf(candidate.Oid, child.Oid); above {
+				break
+			}
+
+			// Keep the tip closest to the child, as detectParent does.
+			closer := parentOid == nil
+			if !closer {
+				closer, _ = child.Repo.DescendantOf(tip, parentOid)
+			}
+			if closer {
+				parent, parentOid = candidate, tip
+			}
+			break
+		}
+	}
+
+	return parent, parentOid
+}
+
+// reflogTips returns up to MaxReflogTips earlier tips of the branch, newest
+// first, leaving out those already in the base.
+func (c *Comparison) reflogTips() []*git.Oid {
+	tips := []*git.Oid{}
+
+	for n := 1; n <= MaxReflogTips; n++ {
+		object, err := c.Repo.RevparseSingle(fmt.Sprintf("%s@{%d}", c.Branch.Reference.Name(), n))
+		if err != nil {
+			break
+		}
+		oid := object.Id()
+		object.Free()
+
+		if oid.Equal(c.Oid) || oid.Equal(c.BaseOid) {
+			continue
+		}
+		if inBase, err := c.Repo.DescendantOf(c.BaseOid, oid); err != nil || inBase {
+			continue
+		}
+		tips = append(tips, oid)
+	}
+
+	return tips
+}
+
+// RecordStacks saves the parent of every stacked branch, and the parent tip it
+// is based on, in `branch.<name>.gbparent` and `branch.<name>.gbparentoid`.
+func (cs Comparisons) RecordStacks(repo *git.Repository) error {
+	config, err := repo.Config()
+	if err != nil {
+		return err
+	}
+
+	for _, comp := range cs {
+		if comp.Parent == nil {
+			continue
+		}
+
+		name, _ := config.LookupString(StackParentKey(comp.Name()))
+		oid, _ := config.LookupString(StackParentOidKey(comp.Name()))
+		if name == comp.Parent.Name() && oid == comp.ParentBaseOid.String() {
+			continue
+		}
+
+		if err := config.SetString(StackParentKey(comp.Name()), comp.Parent.Name()); err != nil {
+			return err
+		}
+		if err := config.SetString(StackParentOidKey(comp.Name()), comp.ParentBaseOid.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IsStackedOn reports whether the tip of the candidate is a strict ancestor of
+// this branch. Merged branches and the base branch never act as parents.
+func (c *Comparison) IsStackedOn(candidate *Comparison, baseBranch string) (bool, error) {
+	if candidate == c || candidate.Err != nil || candidate.IsMerged || candidate.IsBase(baseBranch) {
+		return false, nil
+	}
+
+	if candidate.Oid.Equal(c.Oid) {
+		return false, nil
+	}
+
+	return descendantOf(c, candidate)
+}
+
+func descendantOf(c *Comparison, ancestor *Comparison) (bool, error) {
+	descendant, err := c.Repo.DescendantOf(c.Oid, ancestor.Oid)
+	if err != nil {
+		return false, fmt.Errorf("could not get descendant of '%s' and '%s': %w", c.Oid.String(), ancestor.Oid.String(), err)
+	}
+	return descendant, nil
+}
+
+// Stacked orders the comparisons as a tree: every branch is followed by the
+// branches stacked on it, and Depth is set accordingly. Siblings keep their
+// relative order. A branch that can't be reached from a root, such as one in
+// a cycle of recorded parents, loses its parent and becomes a root.
+func (cs Comparisons) Stacked() Comparisons {
+	children := make(map[*Comparison]Comparisons)
+	roots := make(Comparisons, 0)
+
+	for _, comp := range cs {
+		if comp.Parent == nil {
+			roots = append(roots, comp)
+		} else {
+			children[comp.Parent] = append(children[comp.Parent], comp)
+		}
+	}
+
+	ordered := make(Comparisons, 0, len(cs))
+	reached := make(map[*Comparison]bool)
+
+	var walk func(comp *Comparison, depth int)
+	walk = func(comp *Comparison, depth int) {
+		if reached[comp] {
+			return
+		}
+		reached[comp] = true
+
+		comp.Depth = depth
+		ordered = append(ordered, comp)
+		for _, child := range children[comp] {
+			walk(child, depth+1)
+		}
+	}
+
+	for _, root := range roots {
+		walk(root, 0)
+	}
+
+	for _, comp := range cs {
+		if reached[comp] {
+			continue
+		}
+
+		comp.Parent = nil
+		comp.ParentBaseOid = nil
+		comp.ParentAhead, comp.ParentBehind = 0, 0
+		walk(comp, 0)
+	}
+
+	return ordered
+}
+
+// RestackStep rebases the commits of Branch that are not in Upstream, the
+// parent tip the branch was built on, onto the current tip of Parent.
+type RestackStep struct {
+	Branch   string
+	Parent   string
+	Upstream string
+}
+
+// PlanRestack returns the steps that rebase the stacked branches, parents
+// before children. When branchName is set, only that branch and the branches
+// stacked on it are included.
+func PlanRestack(comparisons Comparisons, branchName string) []RestackStep {
+	selected := make(map[*Comparison]bool)
+	steps := []RestackStep{}
+
+	for _, comp := range comparisons.Stacked() {
+		if branchName != "" && comp.Name() != branchName && !selected[comp.Parent] {
+			continue
+		}
+		selected[comp] = true
+
+		if comp.Parent == nil {
+			continue
+		}
+
+		steps = append(steps, RestackStep{
+			Branch:   comp.Name(),
+			Parent:   comp.Parent.Name(),
+			Upstream: comp.ParentBaseOid.String(),
+		})
+	}
+
+	return steps
+}
@@ -0,0 +1,169 @@
+package gb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDetectStacks(t *testing.T) {
+	f := newFixture(t)
+
+	c1 := f.commit(nil, "c1", map[string]string{"a.txt": "1"})
+	f.branch("main", c1)
+	f.checkout("main")
+
+	a1 := f.commit(c1, "a1", map[string]string{"b.txt": "1"})
+	f.branch("feature-a", a1)
+
+	b1 := f.commit(a1, "b1", map[string]string{"b.txt": "2"})
+	b2 := f.commit(b1, "b2", map[string]string{"b.txt": "3"})
+	f.branch("feature-a-part2", b2)
+
+	p1 := f.commit(b2, "p1", map[string]string{"b.txt": "4"})
+	f.branch("feature-a-part3", p1)
+
+	statuses, err := List(context.Background(), f.repo, f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := byName(statuses)
+
+	if s := got["feature-a"]; s.Parent != "" || s.Depth != 0 {
+		t.Errorf("feature-a: parent=%q depth=%d, want a root", s.Parent, s.Depth)
+	}
+
+	part2 := got["feature-a-part2"]
+	if part2.Parent != "feature-a" || part2.Depth != 1 {
+		t.Errorf("feature-a-part2: parent=%q depth=%d, want feature-a 1", part2.Parent, part2.Depth)
+	}
+	if ahead, behind := part2.RelativeAheadBehind(); ahead != 2 || behind != 0 {
+		t.Errorf("feature-a-part2: %d ahead %d behind its parent, want 2 0", ahead, behind)
+	}
+	if part2.Ahead != 3 {
+		t.Errorf("feature-a-part2: %d ahead of main, want 3", part2.Ahead)
+	}
+
+	if s := got["feature-a-part3"]; s.Parent != "feature-a-part2" || s.Depth != 2 {
+		t.Errorf("feature-a-part3: parent=%q depth=%d, want feature-a-part2 2", s.Parent, s.Depth)
+	}
+
+	opts := f.options()
+	opts.Flat = true
+	statuses, err = List(context.Background(), f.repo, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := byName(statuses)["feature-a-part3"]; s.Parent != "" || s.Depth != 0 {
+		t.Errorf("--flat still nested feature-a-part3 under %q", s.Parent)
+	}
+
+	config, err := f.repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent, err := config.LookupString(StackParentKey("feature-a-part2")); err == nil {
+		t.Errorf("listing recorded the parent %q of feature-a-part2", parent)
+	}
+
+	opts = f.options()
+	opts.RecordStacks = true
+	if _, err := List(context.Background(), f.repo, opts); err != nil {
+		t.Fatal(err)
+	}
+	if parent, _ := config.LookupString(StackParentKey("feature-a-part2")); parent != "feature-a" {
+		t.Errorf("recorded parent %q of feature-a-part2, want feature-a", parent)
+	}
+}
+
+func TestStackedParentCycle(t *testing.T) {
+	f := newFixture(t)
+
+	c1 := f.commit(nil, "c1", map[string]string{"a.txt": "1"})
+	f.branch("main", c1)
+	f.checkout("main")
+
+	f.branch("feature-a", f.commit(c1, "a1", map[string]string{"b.txt": "a"}))
+	f.branch("feature-b", f.commit(c1, "b1", map[string]string{"c.txt": "b"}))
+
+	// Recorded parents pointing at each other, as a rename or an amend can
+	// leave behind.
+	config, err := f.repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.SetString(StackParentKey("feature-a"), "feature-b")
+	config.SetString(StackParentOidKey("feature-a"), c1.String())
+	config.SetString(StackParentKey("feature-b"), "feature-a")
+	config.SetString(StackParentOidKey("feature-b"), c1.String())
+
+	statuses, err := List(context.Background(), f.repo, f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := byName(statuses)
+	if len(statuses) != 3 {
+		t.Fatalf("got %d branches, want 3", len(statuses))
+	}
+	if got["feature-a"].Parent != "" && got["feature-b"].Parent != "" {
+		t.Error("the cycle was not broken")
+	}
+}
+
+func TestPlanRestack(t *testing.T) {
+	f := newFixture(t)
+
+	c1 := f.commit(nil, "c1", map[string]string{"a.txt": "1"})
+	f.branch("main", c1)
+	f.checkout("main")
+
+	a1 := f.commit(c1, "a1", map[string]string{"b.txt": "1"})
+	f.branch("feature-a", a1)
+	b1 := f.commit(a1, "b1", map[string]string{"b.txt": "2"})
+	f.branch("feature-b", b1)
+	o1 := f.commit(c1, "o1", map[string]string{"c.txt": "1"})
+	f.branch("other", o1)
+	p1 := f.commit(o1, "p1", map[string]string{"c.txt": "2"})
+	f.branch("other-part2", p1)
+
+	comparisons, err := Compare(context.Background(), f.repo, f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := PlanRestack(comparisons, "feature-a")
+	if len(steps) != 1 || steps[0].Branch != "feature-b" || steps[0].Parent != "feature-a" || steps[0].Upstream != a1.String() {
+		t.Errorf("unexpected plan for feature-a: %+v", steps)
+	}
+
+	if steps := PlanRestack(comparisons, ""); len(steps) != 2 {
+		t.Errorf("got %d steps for every stack, want 2", len(steps))
+	}
+}
+
+func TestRestackAfterParentMoved(t *testing.T) {
+	f := newFixture(t)
+
+	c1 := f.commit(nil, "c1", map[string]string{"a.txt": "1"})
+	f.branch("main", c1)
+	f.checkout("main")
+
+	a1 := f.commit(c1, "a1", map[string]string{"b.txt": "1"})
+	f.branch("feature-a", a1)
+	b1 := f.commit(a1, "b1", map[string]string{"b.txt": "2"})
+	f.branch("feature-b", b1)
+
+	// feature-a is amended before gb ever looked at the stack.
+	f.branch("feature-a", f.commit(c1, "a1 amended", map[string]string{"b.txt": "1!"}))
+
+	comparisons, err := Compare(context.Background(), f.repo, f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := PlanRestack(comparisons, "")
+	if len(steps) != 1 || steps[0].Branch != "feature-b" || steps[0].Parent != "feature-a" || steps[0].Upstream != a1.String() {
+		t.Errorf("got %+v, want feature-b restacked from %s onto feature-a", steps, a1)
+	}
+}
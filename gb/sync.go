@@ -0,0 +1,187 @@
+package gb
+
+import (
+	"fmt"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+const (
+	SyncUpdated   = "updated"
+	SyncConflicts = "conflicts"
+	SyncUpToDate  = "up-to-date"
+	SyncSkipped   = "skipped"
+)
+
+type SyncResult struct {
+	Comparison *Comparison
+	Status     string
+	Detail     string
+}
+
+// RebaseInMemory replays the commits of the branch on top of the base without
+// touching the worktree. It returns the new tip, or the conflicting paths of
+// the first commit that does not apply.
+func RebaseInMemory(repo *git.Repository, branch *git.Branch, base_oid *git.Oid) (*git.Oid, []string, error) {
+	branchCommit, err := repo.AnnotatedCommitFromRef(branch.Reference)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	upstream, err := repo.LookupAnnotatedCommit(base_oid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rebase, err := repo.InitRebase(branchCommit, upstream, nil, &git.RebaseOptions{InMemory: 1})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rebase.Free()
+
+	committer, err := repo.DefaultSignature()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tip := base_oid
+	for {
+		op, err := rebase.Next()
+		if git.IsErrorCode(err, git.ErrorCodeIterOver) {
+			break
+		}
+		if err != nil {
+			rebase.Abort()
+			return nil, nil, err
+		}
+
+		index, err := rebase.InmemoryIndex()
+		if err != nil {
+			rebase.Abort()
+			return nil, nil, err
+		}
+
+		if index.HasConflicts() {
+			paths, err := conflictPaths(index)
+			index.Free()
+			rebase.Abort()
+			return nil, paths, err
+		}
+		index.Free()
+
+		original, err := repo.LookupCommit(op.Id)
+		if err != nil {
+			rebase.Abort()
+			return nil, nil, err
+		}
+
+		oid := new(git.Oid)
+		err = rebase.Commit(oid, original.Author(), committer, original.Message())
+		if git.IsErrorCode(err, git.ErrorCodeApplied) {
+			continue
+		}
+		if err != nil {
+			rebase.Abort()
+			return nil, nil, err
+		}
+		tip = oid
+	}
+
+	if err := rebase.Finish(); err != nil {
+		return nil, nil, err
+	}
+
+	return tip, nil, nil
+}
+
+// MergeInMemory merges the base into the branch without touching the
+// worktree. It returns the merge commit, or the conflicting paths.
+func MergeInMemory(repo *git.Repository, branchName string, branch_oid, base_oid *git.Oid, baseName string) (*git.Oid, []string, error) {
+	ours, err := repo.LookupCommit(branch_oid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	theirs, err := repo.LookupCommit(base_oid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index, err := repo.MergeCommits(ours, theirs, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer index.Free()
+
+	if index.HasConflicts() {
+		paths, err := conflictPaths(index)
+		return nil, paths, err
+	}
+
+	tree, err := index.WriteTreeTo(repo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signature, err := repo.DefaultSignature()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	message := fmt.Sprintf("Merge branch '%s' into %s\n", baseName, branchName)
+	oid, err := repo.CreateCommitFromIds("", signature, signature, message, tree, branch_oid, base_oid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return oid, nil, nil
+}
+
+// Sync brings the branch of the comparison up to date with the base using the
+// given strategy, rebase or merge. The branch ref is only moved when the whole operation
+// applies cleanly.
+func Sync(comp *Comparison, baseName, strategy string, checkedOut map[string]Worktree) SyncResult {
+	if comp.Behind == 0 {
+		return SyncResult{comp, SyncUpToDate, ""}
+	}
+
+	if worktree, ok := checkedOut[comp.Branch.Reference.Name()]; ok {
+		dirty, err := worktree.IsDirty()
+		if err != nil {
+			return SyncResult{comp, SyncSkipped, err.Error()}
+		}
+		if dirty {
+			return SyncResult{comp, SyncSkipped, "local changes in " + worktree.Path}
+		}
+	}
+
+	var (
+		oid   *git.Oid
+		paths []string
+		err   error
+	)
+
+	switch strategy {
+	case "rebase":
+		oid, paths, err = RebaseInMemory(comp.Repo, comp.Branch, comp.BaseOid)
+	case "merge":
+		oid, paths, err = MergeInMemory(comp.Repo, comp.Name(), comp.Oid, comp.BaseOid, baseName)
+	default:
+		return SyncResult{comp, SyncSkipped, fmt.Sprintf("unknown strategy '%s', expected rebase or merge", strategy)}
+	}
+
+	if err != nil {
+		return SyncResult{comp, SyncSkipped, err.Error()}
+	}
+
+	if len(paths) > 0 {
+		return SyncResult{comp, SyncConflicts, fmt.Sprintf("%d conflicting paths", len(paths))}
+	}
+
+	msg := fmt.Sprintf("gb sync: %s onto %s", strategy, baseName)
+	if err := MoveBranch(comp.Repo, comp.Branch, oid, msg); err != nil {
+		return SyncResult{comp, SyncSkipped, err.Error()}
+	}
+
+	return SyncResult{comp, SyncUpdated, fmt.Sprintf("was %d behind", comp.Behind)}
+}
@@ -0,0 +1,37 @@
+package gb
+
+import (
+	"context"
+	"testing"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+func TestSyncUnknownStrategy(t *testing.T) {
+	f := newListFixture(t)
+
+	comparisons, err := Compare(context.Background(), f.repo, f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, comp := range comparisons {
+		if comp.Name() != "feature" {
+			continue
+		}
+
+		tip := comp.Oid.String()
+		result := Sync(comp, "main", "squash", nil)
+		if result.Status != SyncSkipped {
+			t.Errorf("got %s (%s), want %s", result.Status, result.Detail, SyncSkipped)
+		}
+
+		branch, err := f.repo.LookupBranch("feature", git.BranchLocal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if branch.Target().String() != tip {
+			t.Error("feature moved")
+		}
+	}
+}
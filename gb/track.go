@@ -0,0 +1,97 @@
+package gb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// TrackedUpstreams returns the full ref names of the remote-tracking branches
+// that are the upstream of a local branch.
+func TrackedUpstreams(repo *git.Repository) (map[string]bool, error) {
+	branch_iterator, err := repo.NewBranchIterator(git.BranchLocal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches for '%s': %w", repo.Workdir(), err)
+	}
+	defer branch_iterator.Free()
+
+	tracked := make(map[string]bool)
+	err = branch_iterator.ForEach(func(branch *git.Branch, _ git.BranchType) error {
+		upstream, err := repo.UpstreamName(branch.Reference.Name())
+		if err == nil {
+			tracked[upstream] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tracked, nil
+}
+
+// CompareUntracked executes the comparison of the remote-tracking branches of
+// the remote, or of every remote when remoteName is empty, that no local
+// branch tracks. Options are used as in CompareRemote.
+func CompareUntracked(ctx context.Context, repo *git.Repository, remoteName string, opts Options) (Comparisons, error) {
+	comparisons, err := CompareRemote(ctx, repo, remoteName, opts)
+	if comparisons == nil {
+		return nil, err
+	}
+
+	tracked, terr := TrackedUpstreams(repo)
+	if terr != nil {
+		return nil, terr
+	}
+
+	untracked := make(Comparisons, 0)
+	for _, comp := range comparisons {
+		if !tracked[comp.Branch.Reference.Name()] {
+			untracked = append(untracked, comp)
+		}
+	}
+	return untracked, err
+}
+
+// Track creates a local branch at the tip of the remote-tracking branch, such
+// as origin/feature, with its upstream set to it. The local branch is named
+// after the remote one without the remote unless name is given.
+func Track(repo *git.Repository, remoteBranch string, name string) (*git.Branch, error) {
+	remote, err := repo.LookupBranch(remoteBranch, git.BranchRemote)
+	if err != nil {
+		return nil, fmt.Errorf("could not find remote branch '%s': %w", remoteBranch, err)
+	}
+
+	if name == "" {
+		remoteName, err := repo.RemoteName(remote.Reference.Name())
+		if err != nil {
+			return nil, fmt.Errorf("could not find the remote of '%s': %w", remoteBranch, err)
+		}
+		name = strings.TrimPrefix(remoteBranch, remoteName+"/")
+	}
+
+	if _, err := repo.LookupBranch(name, git.BranchLocal); err == nil {
+		return nil, fmt.Errorf("branch '%s' already exists", name)
+	}
+
+	if remote.Target() == nil {
+		return nil, fmt.Errorf("'%s' is not a direct reference", remoteBranch)
+	}
+
+	commit, err := repo.LookupCommit(remote.Target())
+	if err != nil {
+		return nil, fmt.Errorf("could not lookup commit of '%s': %w", remoteBranch, err)
+	}
+
+	branch, err := repo.CreateBranch(name, commit, false)
+	if err != nil {
+		return nil, fmt.Errorf("could not create branch '%s': %w", name, err)
+	}
+
+	if err := branch.SetUpstream(remoteBranch); err != nil {
+		return nil, fmt.Errorf("could not set the upstream of '%s': %w", name, err)
+	}
+
+	return branch, nil
+}
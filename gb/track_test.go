@@ -0,0 +1,68 @@
+package gb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUntrackedAndTrack(t *testing.T) {
+	upstream := newBareFixture(t)
+	c1 := upstream.commit(nil, "c1", map[string]string{"a.txt": "1"})
+	c2 := upstream.commit(c1, "c2", map[string]string{"a.txt": "2"})
+	upstream.branch("main", c2)
+	upstream.branch("old", c1)
+	f1 := upstream.commit(c2, "f1", map[string]string{"b.txt": "1"})
+	upstream.branch("feature", f1)
+
+	f := newFixture(t)
+	if _, err := f.repo.Remotes.Create("origin", upstream.dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := Fetch(f.repo, []string{"origin"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Track(f.repo, "origin/main", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	comparisons, err := CompareUntracked(context.Background(), f.repo, "", f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := []BranchStatus{}
+	for _, comp := range comparisons {
+		statuses = append(statuses, comp.Status("main"))
+	}
+
+	got := byName(statuses)
+	if len(got) != 2 {
+		t.Fatalf("got %v, want origin/old and origin/feature", got)
+	}
+	if feature := got["origin/feature"]; feature.Ahead != 1 || feature.Behind != 0 {
+		t.Errorf("origin/feature: ahead=%d behind=%d, want 1 0", feature.Ahead, feature.Behind)
+	}
+	if old := got["origin/old"]; old.Behind != 1 || !old.IsMerged {
+		t.Errorf("origin/old: behind=%d merged=%v, want 1 true", old.Behind, old.IsMerged)
+	}
+
+	branch, err := Track(f.repo, "origin/feature", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upstream, err := f.repo.UpstreamName(branch.Reference.Name()); err != nil || upstream != "refs/remotes/origin/feature" {
+		t.Errorf("upstream of feature: got %q, %v", upstream, err)
+	}
+
+	comparisons, err = CompareUntracked(context.Background(), f.repo, "origin", f.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comparisons) != 1 || comparisons[0].Name() != "origin/old" {
+		t.Errorf("got %d untracked branches, want only origin/old", len(comparisons))
+	}
+
+	if _, err := Track(f.repo, "origin/feature", ""); err == nil {
+		t.Error("expected an error when the local branch exists")
+	}
+}
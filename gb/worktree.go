@@ -0,0 +1,148 @@
+package gb
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// Worktree is a working directory of the repository and the ref checked out
+// in it. Head is the branch being rebased during a rebase, and empty when HEAD
+// is otherwise detached.
+type Worktree struct {
+	Path string
+	Head string
+}
+
+func readTrimmed(path string) string {
+	bits, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(bits))
+}
+
+// commonDir returns the git directory shared by all worktrees.
+func commonDir(repo *git.Repository) string {
+	dir := repo.Path()
+	if common := readTrimmed(filepath.Join(dir, "commondir")); common != "" {
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(dir, common)
+		}
+		return filepath.Clean(common)
+	}
+	return dir
+}
+
+// Worktrees lists the working directory of the repository along with the
+// linked worktrees registered in `.git/worktrees`.
+func Worktrees(repo *git.Repository) []Worktree {
+	worktrees := []Worktree{}
+	seen := make(map[string]bool)
+
+	if repo.Workdir() != "" {
+		head := rebaseHeadName(repo.Path())
+		if detached, err := repo.IsHeadDetached(); err == nil && !detached {
+			if ref, err := repo.Head(); err == nil {
+				head = ref.Name()
+			}
+		}
+
+		path := filepath.Clean(repo.Workdir())
+		worktrees = append(worktrees, Worktree{Path: path, Head: head})
+		seen[path] = true
+	}
+
+	common := commonDir(repo)
+
+	// The main worktree when running from a linked one.
+	if common != filepath.Clean(repo.Path()) && filepath.Base(common) == ".git" {
+		path := filepath.Dir(common)
+		if !seen[path] {
+			head := strings.TrimPrefix(readTrimmed(filepath.Join(common, "HEAD")), "ref: ")
+			if !strings.HasPrefix(head, "refs/") {
+				head = rebaseHeadName(common)
+			}
+			worktrees = append(worktrees, Worktree{Path: path, Head: head})
+			seen[path] = true
+		}
+	}
+
+	entries, _ := ioutil.ReadDir(filepath.Join(common, "worktrees"))
+	for _, entry := range entries {
+		dir := filepath.Join(common, "worktrees", entry.Name())
+
+		gitdir := readTrimmed(filepath.Join(dir, "gitdir"))
+		if gitdir == "" {
+			continue
+		}
+
+		path := filepath.Dir(gitdir)
+		if seen[path] {
+			continue
+		}
+
+		head := strings.TrimPrefix(readTrimmed(filepath.Join(dir, "HEAD")), "ref: ")
+		if !strings.HasPrefix(head, "refs/") {
+			head = rebaseHeadName(dir)
+		}
+
+		worktrees = append(worktrees, Worktree{Path: path, Head: head})
+		seen[path] = true
+	}
+
+	return worktrees
+}
+
+// CheckedOut maps the ref name of every checked out branch to its worktree.
+func CheckedOut(repo *git.Repository) map[string]Worktree {
+	checkedOut := make(map[string]Worktree)
+	for _, worktree := range Worktrees(repo) {
+		if worktree.Head != "" {
+			checkedOut[worktree.Head] = worktree
+		}
+	}
+	return checkedOut
+}
+
+// IsDirty reports whether the worktree has staged or unstaged changes to
+// tracked files.
+func (w Worktree) IsDirty() (bool, error) {
+	d, err := w.Dirtiness()
+	if err != nil {
+		return false, err
+	}
+	return d.Staged+d.Unstaged > 0, nil
+}
+
+// MoveBranch points the branch at a new commit. When the branch is checked
+// out in one of the worktrees, that worktree is updated first.
+func MoveBranch(repo *git.Repository, branch *git.Branch, oid *git.Oid, msg string) error {
+	if worktree, ok := CheckedOut(repo)[branch.Reference.Name()]; ok {
+		wrepo, err := git.OpenRepository(worktree.Path)
+		if err != nil {
+			return err
+		}
+		defer wrepo.Free()
+
+		commit, err := wrepo.LookupCommit(oid)
+		if err != nil {
+			return err
+		}
+
+		tree, err := commit.Tree()
+		if err != nil {
+			return err
+		}
+
+		err = wrepo.CheckoutTree(tree, &git.CheckoutOptions{Strategy: git.CheckoutSafe})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := branch.Reference.SetTarget(oid, msg)
+	return err
+}
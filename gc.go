@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/urfave/cli"
+	"github.com/vroy/git-gb/gb"
+)
+
+func gc(ctx *cli.Context) error {
+	repo := NewRepo()
+
+	rules, err := gb.LoadRules(repo)
+	check(err)
+
+	if len(rules) == 0 {
+		exit("No retention rules: add some with git config --add %s '<conditions> -> <action>'", gb.GCRuleKey)
+	}
+
+	opts := gb.DefaultOptions()
+	opts.Flat = true
+	opts.Worktrees = true
+	comparisons, baseBranch := compare(repo, ctx.Args(), opts)
+
+	protection, err := gb.NewProtection(repo, baseBranch)
+	check(err)
+
+	plan := gb.PlanGC(comparisons, rules, protection.Protected, gb.NewGCContext(repo))
+
+	apply := ctx.Bool("apply")
+	journalPath := gb.GCJournalPath(repo)
+	now := time.Now()
+
+	width := comparisons.MaxBranchLength()
+	failed := false
+	acted := 0
+
+	for _, step := range plan {
+		color := Reset
+		switch step.Action {
+		case gb.ActionDelete:
+			color = Red
+		case gb.ActionArchive:
+			color = Yellow
+		}
+
+		if step.Action == gb.ActionKeep && !ctx.Bool("verbose") {
+			continue
+		}
+
+		if apply {
+			if err := step.Apply(repo, journalPath, now); err != nil {
+				fmt.Printf("%s%-7s | %-*s | %s\n", Red, "failed", width, step.Name(), err)
+				failed = true
+				continue
+			}
+			if step.Action != gb.ActionKeep {
+				acted++
+			}
+		}
+
+		fmt.Printf("%s%-7s | %-*s | %s\n", color, step.Action, width, step.Name(), step.Reason())
+	}
+
+	fmt.Print(Reset)
+	if !apply {
+		fmt.Println("Run again with --apply to act on the plan.")
+	} else if acted > 0 {
+		fmt.Printf("Logged %d actions to %s\n", acted, journalPath)
+	}
+
+	if failed {
+		os.Exit(ExitError)
+	}
+	return nil
+}
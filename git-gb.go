@@ -1,19 +1,18 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"sort"
+	"regexp"
 	"strings"
-	"time"
 	"unicode/utf8"
 
-	ioutil "io/ioutil"
-
 	git "github.com/libgit2/git2go/v34"
 	"github.com/mgutz/ansi"
 	"github.com/urfave/cli"
+	"github.com/vroy/git-gb/gb"
 )
 
 var (
@@ -24,318 +23,396 @@ var (
 	Bold          = ansi.ColorCode("reset+b")
 )
 
-const CachePath = ".git/go_gb_cache.json"
+// Exit codes, documented in the README.
+const (
+	ExitOK              = 0
+	ExitError           = 1
+	ExitNoRepository    = 2
+	ExitBaseNotFound    = 3
+	ExitPartialFailure  = 4
+	ExitMatched         = 5
+	ExitPolicyViolation = 6
+)
 
 func exit(msg string, args ...interface{}) {
-	msg = fmt.Sprintf(msg, args...)
-	fmt.Println(msg)
-	os.Exit(1)
+	exitWith(ExitError, msg, args...)
 }
 
-func NewRepo() *git.Repository {
-	for {
-		wd, err := os.Getwd()
-		if err != nil {
-			exit("Error getting current directory: %s", err)
-		}
-
-		if wd == "/" {
-			exit("Could not open repository")
-		}
+func exitWith(code int, msg string, args ...interface{}) {
+	msg = fmt.Sprintf(msg, args...)
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(code)
+}
 
-		repo, err := git.OpenRepository(wd)
-		if err != nil {
-			os.Chdir("..")
-			continue
-		}
-		return repo
+// exitCode maps an error returned by the gb package to an exit code.
+func exitCode(err error) int {
+	var partial *gb.PartialError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, gb.ErrNoRepository):
+		return ExitNoRepository
+	case errors.Is(err, gb.ErrBaseNotFound):
+		return ExitBaseNotFound
+	case errors.As(err, &partial):
+		return ExitPartialFailure
+	case errors.Is(err, gb.ErrPolicyViolation):
+		return ExitPolicyViolation
+	default:
+		return ExitError
 	}
 }
 
-func NewBranchIterator(repo *git.Repository) *git.BranchIterator {
-	i, err := repo.NewBranchIterator(git.BranchLocal)
+// check exits with the error, if any.
+func check(err error) {
 	if err != nil {
-		wd, _ := os.Getwd()
-		exit("Failed to list branches for '%s'", wd)
+		exitWith(exitCode(err), "Error: %s", err)
 	}
-	return i
 }
 
-func LookupBaseOid(repo *git.Repository, baseBranchName string) *git.Oid {
-	base_branch, err := repo.LookupBranch(baseBranchName, git.BranchLocal)
-	if err != nil {
-		exit("Error looking up base branch '%s'", baseBranchName)
+// summarize prints the branches that could not be compared on stderr.
+func summarize(partial *gb.PartialError) {
+	fmt.Fprintf(os.Stderr, "%s%s:\n", Reset, partial)
+	for _, err := range partial.Errors {
+		fmt.Fprintf(os.Stderr, "  %s\n", err)
 	}
-
-	return base_branch.Target()
 }
 
-type Comparison struct {
-	Repo    *git.Repository
-	BaseOid *git.Oid
-	Branch  *git.Branch
-	Oid     *git.Oid
+func NewRepo() *git.Repository {
+	wd, err := os.Getwd()
+	if err != nil {
+		exit("Error getting current directory: %s", err)
+	}
 
-	IsMerged bool
-	Ahead    int
-	Behind   int
+	repo, err := gb.OpenRepository(wd)
+	check(err)
+	return repo
 }
 
-func NewComparison(repo *git.Repository, base_oid *git.Oid, branch *git.Branch, store CacheStore) *Comparison {
-	c := new(Comparison)
-
-	c.Repo = repo
-	c.BaseOid = base_oid
-
-	c.Branch = branch
-	c.Oid = branch.Target()
+// compare runs the comparisons for the subcommands that work on every local
+// branch, against the base given as first argument. Branches that could not be
+// compared are reported on stderr and left for the caller to skip.
+func compare(repo *git.Repository, args cli.Args, opts gb.Options) (gb.Comparisons, string) {
+	opts.Base = gb.BaseBranch(repo, args.First())
 
-	cache := store[c.CacheKey()]
-
-	if cache != nil {
-		c.Ahead = cache.Ahead
-		c.Behind = cache.Behind
-		c.IsMerged = cache.IsMerged
+	comparisons, err := gb.Compare(context.Background(), repo, opts)
+	var partial *gb.PartialError
+	if errors.As(err, &partial) {
+		summarize(partial)
 	} else {
-		c.IsMerged = false
-		c.Ahead = -1
-		c.Behind = -1
+		check(err)
 	}
 
-	return c
+	return comparisons, opts.Base
 }
 
-func (c *Comparison) Name() string {
-	name, err := c.Branch.Name()
-	if err != nil {
-		exit("Can't get branch name for '%s'", c.Oid)
-	}
-	return name
-}
-
-func (c *Comparison) IsHead() bool {
-	head, err := c.Branch.IsHead()
-	if err != nil {
-		exit("Can't get IsHead for '%s'", c.Name())
+func ColorCode(status gb.BranchStatus) string {
+	switch status.Color() {
+	case "green":
+		return Green
+	case "red":
+		return Red
+	default:
+		return Yellow
 	}
-	return head
 }
 
-func (c *Comparison) Commit() *git.Commit {
-	commit, err := c.Repo.LookupCommit(c.Oid)
-	if err != nil {
-		exit("Could not lookup commit '%s'.", c.Oid.String())
-	}
-	return commit
+func FormattedWhen(status gb.BranchStatus) string {
+	return status.When.Format("2006-01-02 15:04PM")
 }
 
-func (c *Comparison) ColorCode() string {
-	hours, _ := time.ParseDuration("336h") // two weeks
-	two_weeks := time.Now().Add(-hours)
+func MaxBranchLength(statuses []gb.BranchStatus) int {
+	max := 30
 
-	if c.IsHead() {
-		return Green
-	} else if c.When().Before(two_weeks) {
-		return Red
-	} else {
-		return Yellow
+	for _, status := range statuses {
+		length := utf8.RuneCountInString(status.TreeName())
+		if length > max {
+			max = length
+		}
 	}
+	return max
 }
 
-func (c *Comparison) When() time.Time {
-	sig := c.Commit().Committer()
-	return sig.When
-}
+// FormattedPullRequest is the number, state and review status of the pull
+// request of the branch, if any.
+func FormattedPullRequest(status gb.BranchStatus) string {
+	pr := status.PullRequest
+	if pr == nil {
+		return ""
+	}
 
-func (c *Comparison) FormattedWhen() string {
-	return c.When().Format("2006-01-02 15:04PM")
+	if pr.Review == "" {
+		return fmt.Sprintf(" | #%d %s", pr.Number, pr.State)
+	}
+	return fmt.Sprintf(" | #%d %s, %s", pr.Number, pr.State, strings.Replace(pr.Review, "_", " ", -1))
 }
 
-func (c *Comparison) CacheKey() string {
-	strs := []string{c.BaseOid.String(), c.Oid.String()}
-	return strings.Join(strs, "..")
+// FormattedCI is the combined commit status of the branch tip, if any.
+func FormattedCI(status gb.BranchStatus) string {
+	if status.CI == "" {
+		return ""
+	}
+	return " | ci: " + status.CI
 }
 
-func (c *Comparison) SetIsMerged() {
-	if c.Oid.String() == c.BaseOid.String() {
-		c.IsMerged = true
-	} else {
-		merged, err := c.Repo.DescendantOf(c.BaseOid, c.Oid)
-		if err != nil {
-			exit("Could not get descendant of '%s' and '%s'.", c.BaseOid.String(), c.Oid.String())
-		}
-		c.IsMerged = merged
+// FormattedWorktree shows the uncommitted changes of a checked out branch and
+// the stashes created on the branch.
+func FormattedWorktree(status gb.BranchStatus) string {
+	formatted := ""
+	if status.Dirty != nil && status.Dirty.IsDirty() {
+		formatted += " | dirty: " + status.Dirty.String()
+	}
+	if status.Stashes > 0 {
+		formatted += fmt.Sprintf(" | stashes: %d", status.Stashes)
 	}
+	return formatted
 }
 
-func (c *Comparison) SetAheadBehind() {
-	var err error
-	c.Ahead, c.Behind, err = c.Repo.AheadBehind(c.Oid, c.BaseOid)
-	if err != nil {
-		exit("Error getting ahead/behind", c.BaseOid.String())
+func FormattedConflicts(status gb.BranchStatus) string {
+	if !status.ConflictsChecked {
+		return ""
 	}
-}
 
-func (c *Comparison) Execute() {
-	if c.Ahead > -1 && c.Behind > -1 {
-		return
+	if len(status.Conflicts) == 0 {
+		return "clean"
 	}
 
-	c.SetIsMerged()
-	c.SetAheadBehind()
+	return fmt.Sprintf("conflicts: %d", len(status.Conflicts))
 }
 
-type Comparisons []*Comparison
-
-func (cs Comparisons) MaxBranchLength() int {
-	max := 30
+// fetchFlag is the value of --fetch: a bare --fetch fetches the remote of the
+// base branch and --fetch=all every remote.
+type fetchFlag struct {
+	value string
+}
 
-	for _, comp := range cs {
-		length := utf8.RuneCountInString(comp.Name())
-		if length > max {
-			max = length
-		}
+func (f *fetchFlag) Set(value string) error {
+	switch value {
+	case "true":
+		f.value = gb.FetchBase
+	case "false":
+		f.value = ""
+	case gb.FetchAll:
+		f.value = gb.FetchAll
+	default:
+		return fmt.Errorf("expected --fetch or --fetch=all")
 	}
-	return max
+	return nil
 }
 
-type ComparisonsByWhen Comparisons
-
-func (a ComparisonsByWhen) Len() int {
-	return len(a)
+func (f *fetchFlag) String() string {
+	return f.value
 }
 
-func (a ComparisonsByWhen) Swap(i, j int) {
-	a[i], a[j] = a[j], a[i]
+// IsBoolFlag lets --fetch be given without a value.
+func (f *fetchFlag) IsBoolFlag() bool {
+	return true
 }
 
-func (a ComparisonsByWhen) Less(i, j int) bool {
-	return a[i].When().Unix() < a[j].When().Unix()
+// compilePattern compiles the --pattern flag, or returns nil when it is not
+// set.
+func compilePattern(expr string) *regexp.Regexp {
+	if expr == "" {
+		return nil
+	}
+
+	pattern, err := regexp.Compile("(?i)" + expr)
+	if err != nil {
+		exit("Invalid pattern '%s': %s", expr, err)
+	}
+	return pattern
 }
 
-type CacheStore map[string]*Comparison
+// withHeadState prints a header when HEAD is detached or an operation is in
+// progress, and adds the detached HEAD to the rows.
+func withHeadState(repo *git.Repository, baseBranch string, statuses []gb.BranchStatus) []gb.BranchStatus {
+	state, err := gb.ReadHeadState(repo, baseBranch)
+	check(err)
 
-func NewCacheStore() CacheStore {
-	bits, err := ioutil.ReadFile(CachePath)
-	if err != nil {
-		// no-op: `cache.json` will be written on exit.
+	if !state.InProgress() {
+		return statuses
 	}
 
-	y := make(CacheStore)
-	_ = json.Unmarshal(bits, &y)
+	fmt.Printf("%s%s%s\n", Bold, state, Reset)
 
-	return y
+	detached, err := state.DetachedStatus(repo, baseBranch)
+	check(err)
+	if detached == nil {
+		return statuses
+	}
+	return append(statuses[:len(statuses):len(statuses)], *detached)
 }
 
-func (store *CacheStore) WriteToFile() error {
-	b, err := json.Marshal(store)
-	if err != nil {
-		fmt.Printf("Could not save cache to file.")
+func run(ctx *cli.Context) error {
+	repo := NewRepo()
+
+	if ctx.Bool("clear-cache") {
+		os.Remove(gb.CachePath(repo))
+		os.Remove(gb.ProviderCachePath(repo))
 	}
-	ioutil.WriteFile(CachePath, b, 0644)
-	return nil
-}
 
-func computeBaseBranch(repo *git.Repository, args cli.Args) string {
-	fallback := "main"
+	opts := gb.Options{
+		Base:      gb.BaseBranch(repo, ctx.Args().First()),
+		Fetch:     ctx.Generic("fetch").(*fetchFlag).value,
+		Progress:  os.Stderr,
+		Ahead:     ctx.Int("ahead"),
+		Behind:    ctx.Int("behind"),
+		Merged:    ctx.Bool("merged"),
+		NoMerged:  ctx.Bool("no-merged"),
+		Stale:     ctx.Bool("stale"),
+		Pattern:   compilePattern(ctx.String("pattern")),
+		Conflicts: ctx.Bool("conflicts"),
+		Flat:      ctx.Bool("flat"),
+		Worktrees: true,
+	}
 
-	if len(args) > 0 {
-		return args.First()
+	format := ctx.String("format")
+	switch format {
+	case "", "json", "csv", "tsv":
+	default:
+		exit("Invalid format '%s': expected json, csv or tsv", format)
 	}
 
-	config, err := repo.Config()
+	columns, err := gb.ParseColumns(ctx.String("columns"))
 	if err != nil {
-		return fallback
+		exit("Invalid columns: %s", err)
 	}
 
-	defaultBranch, err := config.LookupString("init.defaultBranch")
-	if err != nil {
-		return fallback
+	switch ctx.String("ci") {
+	case "":
+	case "all":
+		opts.CI = true
+	case "failing":
+		opts.CI = true
+		opts.CIFailing = true
+	default:
+		exit("Invalid --ci '%s': expected all or failing", ctx.String("ci"))
 	}
 
-	return defaultBranch
-}
+	opts.PullRequests = ctx.Bool("pr")
 
-func run(ctx *cli.Context) error {
-	if ctx.Bool("clear-cache") {
-		os.Remove(CachePath)
+	if opts.PullRequests || opts.CI {
+		opts.Provider, err = gb.NewProvider(repo)
+		check(err)
 	}
 
-	store := NewCacheStore()
-
-	repo := NewRepo()
+	statuses, err := gb.List(context.Background(), repo, opts)
+	var partial *gb.PartialError
+	if !errors.As(err, &partial) {
+		check(err)
+	}
 
-	baseBranch := computeBaseBranch(repo, ctx.Args())
-	branch_iterator := NewBranchIterator(repo)
-	base_oid := LookupBaseOid(repo, baseBranch)
+	if format != "" {
+		check(writeFormatted(format, columns, statuses))
+	}
 
-	comparisons := make(Comparisons, 0)
+	rows := statuses
+	if format == "" && !ctx.Bool("porcelain") {
+		rows = withHeadState(repo, opts.Base, statuses)
+	}
 
-	// type BranchIteratorFunc func(*Branch, BranchType) error
-	branch_iterator.ForEach(func(branch *git.Branch, btype git.BranchType) error {
-		comp := NewComparison(repo, base_oid, branch, store)
-		comparisons = append(comparisons, comp)
-		return nil
-	})
+	branch_length := MaxBranchLength(rows)
 
-	sort.Sort(ComparisonsByWhen(comparisons))
+	matched := 0
 
-	branch_length := comparisons.MaxBranchLength()
+	for i, status := range rows {
+		// The detached HEAD pseudo-row comes after the branches.
+		if i < len(statuses) && !status.IsBase && status.Err == nil {
+			matched++
+		}
 
-	for _, comp := range comparisons {
-		comp.Execute()
+		if format != "" {
+			continue
+		}
 
-		if comp.Name() == baseBranch {
-			fmt.Printf(
-				"%s%s%s * %-*s\n",
-				Bold,
-				comp.ColorCode(),
-				comp.FormattedWhen(),
-				branch_length, // http://stackoverflow.com/a/28870241
-				comp.Name())
+		if ctx.Bool("porcelain") {
+			fmt.Println(status.Porcelain())
 			continue
 		}
 
-		merged_string := ""
-		if comp.IsMerged {
-			merged_string = "(merged)"
+		description := ""
+		if ctx.Bool("description") && status.Description != "" {
+			description = " | " + status.Description
 		}
 
-		if ctx.Int("ahead") != -1 && ctx.Int("ahead") != comp.Ahead {
+		if status.Err != nil {
+			fmt.Printf(
+				"%s%s%-18s | %-*s | (error: %s)\n",
+				Reset,
+				Red,
+				"",
+				branch_length, // http://stackoverflow.com/a/28870241
+				status.TreeName(),
+				status.Err.Err)
 			continue
 		}
 
-		if ctx.Int("behind") != -1 && ctx.Int("behind") != comp.Behind {
+		if status.IsBase {
+			fmt.Printf(
+				"%s%s%s * %-*s%s%s\n",
+				Bold,
+				ColorCode(status),
+				FormattedWhen(status),
+				branch_length, // http://stackoverflow.com/a/28870241
+				status.Name,
+				FormattedWorktree(status),
+				description)
 			continue
 		}
 
-		if ctx.Bool("merged") && !comp.IsMerged {
-			continue
+		merged_string := ""
+		if status.IsMerged {
+			merged_string = "(merged)"
 		}
 
-		if ctx.Bool("no-merged") && comp.IsMerged {
-			continue
+		if conflicts := FormattedConflicts(status); conflicts != "" {
+			merged_string = fmt.Sprintf("(%s)", conflicts)
 		}
 
+		ahead, behind := status.RelativeAheadBehind()
+
 		fmt.Printf(
-			"%s%s%s | %-*s | behind: %4d | ahead: %4d %s\n",
+			"%s%s%s | %-*s | behind: %4d | ahead: %4d %s%s%s%s%s\n",
 			Reset,
-			comp.ColorCode(),
-			comp.FormattedWhen(),
+			ColorCode(status),
+			FormattedWhen(status),
 			branch_length, // http://stackoverflow.com/a/28870241
-			comp.Name(),
-			comp.Behind,
-			comp.Ahead,
-			merged_string)
+			status.TreeName(),
+			behind,
+			ahead,
+			merged_string,
+			FormattedPullRequest(status),
+			FormattedCI(status),
+			FormattedWorktree(status),
+			description)
+
+		if ctx.Bool("verbose") {
+			for _, path := range status.Conflicts {
+				fmt.Printf("%s    %s\n", Reset, path)
+			}
+		}
+	}
 
-		store[comp.CacheKey()] = comp
+	if partial != nil {
+		summarize(partial)
+		os.Exit(ExitPartialFailure)
 	}
 
-	store.WriteToFile()
+	if ctx.Bool("exit-code") && matched > 0 {
+		os.Exit(ExitMatched)
+	}
 
 	return nil
 }
 
+func renameFlags(verb string) []cli.Flag {
+	return []cli.Flag{
+		cli.BoolFlag{Name: "pattern", Usage: fmt.Sprintf("%s every branch matching the <old> regexp to the <new> replacement, where $1 is the first group.", verb)},
+		cli.BoolFlag{Name: "apply", Usage: fmt.Sprintf("%s the branches matching --pattern instead of listing them.", verb)},
+		cli.BoolFlag{Name: "push", Usage: "push the new name and set it as upstream, deleting the old name on the remote when renaming."},
+	}
+}
+
 func main() {
 	app := cli.NewApp()
 	app.Name = "gb"
@@ -348,7 +425,170 @@ func main() {
 		cli.IntFlag{Name: "behind", Value: -1, Usage: "only show branches that are <behind> commits behind."},
 		cli.BoolFlag{Name: "merged", Usage: "only show branches that are merged."},
 		cli.BoolFlag{Name: "no-merged", Usage: "only show branches that are not merged."},
+		cli.BoolFlag{Name: "stale", Usage: "only show branches without commits in the last two weeks."},
 		cli.BoolFlag{Name: "clear-cache", Usage: "clear cache of comparisons."},
+		cli.GenericFlag{Name: "fetch", Value: &fetchFlag{}, Usage: "fetch the remote of the base branch first, or every remote with --fetch=all, pruning deleted branches."},
+		cli.StringFlag{Name: "pattern", Usage: "only show branches whose name or description matches <pattern> (case-insensitive regexp)."},
+		cli.BoolFlag{Name: "description", Usage: "show the first line of each branch's description."},
+		cli.BoolFlag{Name: "conflicts", Usage: "predict whether unmerged branches merge cleanly into the base."},
+		cli.BoolFlag{Name: "verbose", Usage: "list conflicting paths."},
+		cli.BoolFlag{Name: "flat", Usage: "do not nest stacked branches under their parent branch."},
+		cli.BoolFlag{Name: "exit-code", Usage: "exit with status 5 when any branch other than the base passes the filters."},
+		cli.BoolFlag{Name: "porcelain", Usage: "print one stable, space-separated line per branch for scripts."},
+		cli.BoolFlag{Name: "pr", Usage: "show the pull request of each branch from the provider in gb.provider (uses the network)."},
+		cli.StringFlag{Name: "ci", Usage: "show the CI status of each branch tip from the provider (uses the network): all, or failing to only show failed builds."},
+		cli.StringFlag{Name: "format", Usage: "print the branches as json, csv or tsv, without colors."},
+		cli.StringFlag{Name: "columns", Usage: "comma-separated columns to print with --format, in order."},
+	}
+
+	app.Commands = []cli.Command{
+		{
+			Name:      "archive",
+			Usage:     "move branches to refs/archive/<name>, or archive/<name> tags, and delete them.",
+			ArgsUsage: "<branch|pattern>...",
+			Action:    archive,
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "tag", Usage: "archive as annotated tags keeping the last commit date and description."},
+				cli.BoolFlag{Name: "list", Usage: "list the archived branches against [base] instead."},
+			},
+		},
+		{
+			Name:      "copy",
+			Usage:     "copy a branch with its config, or every branch matching --pattern.",
+			ArgsUsage: "<old> <new>",
+			Action:    copyBranches,
+			Flags:     renameFlags("copy"),
+		},
+		{
+			Name:      "describe",
+			Usage:     "show, set or edit the description of a branch.",
+			ArgsUsage: "<branch>",
+			Action:    describe,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "message, m", Usage: "set the description to <text>."},
+				cli.BoolFlag{Name: "edit", Usage: "edit the description in $EDITOR."},
+			},
+		},
+		{
+			Name:      "gc",
+			Usage:     "delete or archive branches following the retention rules of gb.gc.rule.",
+			ArgsUsage: "[base]",
+			Action:    gc,
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "apply", Usage: "act on the plan and log each action to the journal."},
+				cli.BoolFlag{Name: "verbose", Usage: "also list the branches that are kept."},
+			},
+		},
+		{
+			Name:      "lint",
+			Usage:     "check branch names against the naming policy of .gbpolicy and gb.policy.*.",
+			ArgsUsage: "[base]",
+			Action:    lint,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "remote", Usage: "check the branches of <remote> instead of local branches."},
+			},
+		},
+		{
+			Name:      "metrics",
+			Usage:     "print branch hygiene metrics in the OpenMetrics text format.",
+			ArgsUsage: "[base]",
+			Action:    metrics,
+		},
+		{
+			Name:      "new",
+			Usage:     "create a branch at the tip of the base, if its name follows the naming policy.",
+			ArgsUsage: "<name> [base]",
+			Action:    newBranch,
+		},
+		{
+			Name:      "overlap",
+			Usage:     "list pairs of unmerged branches that change the same files.",
+			ArgsUsage: "[base]",
+			Action:    overlap,
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "merge", Usage: "trial merge each pair to confirm real conflicts."},
+				cli.BoolFlag{Name: "verbose", Usage: "list the shared paths."},
+			},
+		},
+		{
+			Name:      "prune",
+			Usage:     "delete the branches merged or squash-merged into the base, locally or on a remote.",
+			ArgsUsage: "[base]",
+			Action:    prune,
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "apply", Usage: "delete the branches instead of listing them."},
+				cli.StringFlag{Name: "remote", Usage: "prune the branches of <remote> with a single push instead of local branches."},
+				cli.BoolFlag{Name: "no-squash", Usage: "only prune branches merged with a merge commit or fast-forward."},
+			},
+		},
+		{
+			Name:      "rename",
+			Usage:     "rename a branch with its config, or every branch matching --pattern.",
+			ArgsUsage: "<old> <new>",
+			Action:    renameBranches,
+			Flags:     renameFlags("rename"),
+		},
+		{
+			Name:      "report",
+			Usage:     "write a self-contained HTML or Markdown report of every branch.",
+			ArgsUsage: "[base]",
+			Action:    report,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "html", Usage: "write the HTML report to <file>, or stdout for -."},
+				cli.StringFlag{Name: "markdown", Usage: "write the Markdown report to <file>, or stdout for -."},
+			},
+		},
+		{
+			Name:      "restack",
+			Usage:     "rebase stacked branches onto the new tip of their parent branch.",
+			ArgsUsage: "[branch]",
+			Action:    restack,
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "continue", Usage: "resume after resolving a conflict."},
+				cli.BoolFlag{Name: "abort", Usage: "stop and roll back every branch restacked so far."},
+				cli.BoolFlag{Name: "undo", Usage: "reset the branches of the last restack to where they were."},
+			},
+		},
+		{
+			Name:   "serve",
+			Usage:  "serve a read-only dashboard and JSON API of the branches.",
+			Action: serve,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "addr", Value: "localhost:8080", Usage: "listen on <addr>."},
+			},
+		},
+		{
+			Name:      "sync",
+			Usage:     "rebase or merge every unmerged branch onto the latest base.",
+			ArgsUsage: "[base]",
+			Action:    syncBranches,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "strategy", Value: "rebase", Usage: "rebase or merge."},
+				cli.StringFlag{Name: "pattern", Usage: "only sync branches whose name or description matches <pattern>."},
+			},
+		},
+		{
+			Name:      "track",
+			Usage:     "create a local branch tracking the remote branch, named after it unless [name] is given.",
+			ArgsUsage: "<remote-branch> [name]",
+			Action:    track,
+		},
+		{
+			Name:      "unarchive",
+			Usage:     "restore archived branches.",
+			ArgsUsage: "<branch>...",
+			Action:    unarchive,
+		},
+		{
+			Name:      "untracked",
+			Usage:     "list the remote branches that no local branch tracks.",
+			ArgsUsage: "[base]",
+			Action:    untracked,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "remote", Usage: "only list the branches of <remote>."},
+				cli.GenericFlag{Name: "fetch", Value: &fetchFlag{}, Usage: "fetch the remote of the base branch first, or every remote with --fetch=all."},
+			},
+		},
 	}
 
 	app.Run(os.Args)
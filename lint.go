@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli"
+	"github.com/vroy/git-gb/gb"
+)
+
+func lint(ctx *cli.Context) error {
+	repo := NewRepo()
+
+	policy, err := gb.LoadPolicy(repo)
+	check(err)
+
+	protection, err := gb.NewProtection(repo, gb.BaseBranch(repo, ctx.Args().First()))
+	check(err)
+
+	violations, err := policy.Lint(repo, ctx.String("remote"), protection.Matches)
+	check(err)
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	width := 30
+	for _, violation := range violations {
+		if len(violation.Branch) > width {
+			width = len(violation.Branch)
+		}
+	}
+
+	for _, violation := range violations {
+		fmt.Printf("%s%-*s | %s\n", Red, width, violation.Branch, strings.Join(violation.Problems, ", "))
+	}
+	fmt.Print(Reset)
+
+	os.Exit(ExitPolicyViolation)
+	return nil
+}
+
+func newBranch(ctx *cli.Context) error {
+	repo := NewRepo()
+
+	if len(ctx.Args()) == 0 {
+		exit("Usage: git gb new <name> [base]")
+	}
+
+	policy, err := gb.LoadPolicy(repo)
+	check(err)
+
+	name := ctx.Args().First()
+	baseBranch := gb.BaseBranch(repo, ctx.Args().Get(1))
+
+	branch, err := gb.NewBranch(repo, name, baseBranch, policy)
+	check(err)
+
+	fmt.Printf("Created %s at %s (%s)\n", name, branch.Target().String()[:7], baseBranch)
+	return nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/urfave/cli"
+	"github.com/vroy/git-gb/gb"
+)
+
+func metrics(ctx *cli.Context) error {
+	repo := NewRepo()
+
+	opts := gb.DefaultOptions()
+	opts.Flat = true
+	comparisons, baseBranch := compare(repo, ctx.Args(), opts)
+
+	check(gb.WriteMetrics(os.Stdout, gb.RepoPath(repo), baseBranch, comparisons, time.Now()))
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+	"github.com/vroy/git-gb/gb"
+)
+
+func overlap(ctx *cli.Context) error {
+	repo := NewRepo()
+
+	comparisons, baseBranch := compare(repo, ctx.Args(), gb.DefaultOptions())
+
+	unmerged := make(gb.Comparisons, 0)
+	for _, comp := range comparisons {
+		if comp.Err != nil || comp.IsBase(baseBranch) || comp.IsMerged {
+			continue
+		}
+		unmerged = append(unmerged, comp)
+	}
+
+	overlaps, err := gb.FindOverlaps(unmerged)
+	var partial *gb.PartialError
+	if errors.As(err, &partial) {
+		summarize(partial)
+	} else {
+		check(err)
+	}
+
+	if len(overlaps) == 0 {
+		fmt.Println("No unmerged branches change the same files.")
+	}
+
+	for _, o := range overlaps {
+		conflicts := ""
+		if ctx.Bool("merge") {
+			check(o.SetConflicts())
+			if len(o.Conflicts) == 0 {
+				conflicts = " (clean)"
+			} else {
+				conflicts = fmt.Sprintf(" (conflicts: %d)", len(o.Conflicts))
+			}
+		}
+
+		fmt.Printf(
+			"%s%4d shared | %s%s%s <-> %s%s%s%s\n",
+			Reset,
+			len(o.Paths),
+			Bold, o.A.Name(), Reset,
+			Bold, o.B.Name(), Reset,
+			conflicts)
+
+		if ctx.Bool("verbose") {
+			fmt.Printf("    %s\n", strings.Join(o.Paths, "\n    "))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	git "github.com/libgit2/git2go/v34"
+	"github.com/urfave/cli"
+	"github.com/vroy/git-gb/gb"
+)
+
+func printCandidate(action string, color string, width int, name string, detail string) {
+	fmt.Printf("%s%-12s | %-*s | %s\n", color, action, width, name, detail)
+}
+
+func candidateWidth(candidates []gb.PruneCandidate, prefix string) int {
+	max := 30
+	for _, c := range candidates {
+		if length := len(prefix + c.Name); length > max {
+			max = length
+		}
+	}
+	return max
+}
+
+func prune(ctx *cli.Context) error {
+	repo := NewRepo()
+
+	apply := ctx.Bool("apply")
+	squash := !ctx.Bool("no-squash")
+	remoteName := ctx.String("remote")
+
+	baseBranch := gb.BaseBranch(repo, ctx.Args().First())
+	protection, err := gb.NewProtection(repo, baseBranch)
+	check(err)
+
+	if remoteName != "" {
+		return pruneRemote(repo, remoteName, baseBranch, protection, apply, squash)
+	}
+
+	opts := gb.DefaultOptions()
+	opts.Flat = true
+	opts.Worktrees = true
+	comparisons, _ := compare(repo, ctx.Args(), opts)
+
+	// Merged branches checked out with uncommitted changes are kept, but
+	// worth knowing about.
+	for _, comp := range comparisons {
+		if comp.Err == nil && comp.IsMerged && comp.Name() != baseBranch && comp.Dirty != nil && comp.Dirty.IsDirty() {
+			fmt.Printf("%swarning: %s is merged but checked out with uncommitted changes: %s\n", Yellow, comp.Name(), comp.Dirty)
+		}
+	}
+
+	candidates, err := gb.PruneCandidates(comparisons, "", squash, protection.Protected)
+	check(err)
+
+	if len(candidates) == 0 {
+		fmt.Println("No merged branches to prune.")
+		return nil
+	}
+
+	width := candidateWidth(candidates, "")
+	failed := false
+
+	for _, c := range candidates {
+		tip := c.Oid.String()[:7]
+
+		reason := c.Reason()
+		if warnings := c.Warnings(); len(warnings) > 0 {
+			reason += " (warning: " + strings.Join(warnings, ", ") + ")"
+		}
+
+		if !apply {
+			printCandidate("would delete", Yellow, width, c.Name, reason)
+			continue
+		}
+
+		if err := c.Branch.Delete(); err != nil {
+			printCandidate("failed", Red, width, c.Name, err.Error())
+			failed = true
+			continue
+		}
+		printCandidate("deleted", Green, width, c.Name, fmt.Sprintf("%s, was %s", reason, tip))
+	}
+
+	if !apply {
+		fmt.Printf("%sRun again with --apply to delete them.\n", Reset)
+	}
+	if failed {
+		os.Exit(ExitError)
+	}
+	return nil
+}
+
+func pruneRemote(repo *git.Repository, remoteName string, baseBranch string, protection *gb.Protection, apply bool, squash bool) error {
+	opts := gb.DefaultOptions()
+	opts.Base = baseBranch
+
+	comparisons, err := gb.CompareRemote(context.Background(), repo, remoteName, opts)
+	var partial *gb.PartialError
+	if errors.As(err, &partial) {
+		summarize(partial)
+	} else {
+		check(err)
+	}
+
+	prefix := remoteName + "/"
+	candidates, err := gb.PruneCandidates(comparisons, prefix, squash, protection.Matches)
+	check(err)
+
+	if len(candidates) == 0 {
+		fmt.Printf("No merged branches to prune on %s.\n", remoteName)
+		return nil
+	}
+
+	width := candidateWidth(candidates, prefix)
+
+	if !apply {
+		for _, c := range candidates {
+			printCandidate("would delete", Yellow, width, prefix+c.Name, c.Reason())
+		}
+		fmt.Printf("%sRun again with --apply to delete them from %s.\n", Reset, remoteName)
+		return nil
+	}
+
+	names := make([]string, len(candidates))
+	for i, c := range candidates {
+		names[i] = c.Name
+	}
+
+	results, err := gb.PushDeletions(repo, remoteName, names, os.Stderr)
+
+	rejected := false
+	for _, result := range results {
+		name := prefix + strings.TrimPrefix(result.Ref, "refs/heads/")
+		if result.Status == "" {
+			printCandidate("deleted", Green, width, name, "")
+		} else {
+			printCandidate("rejected", Red, width, name, result.Status)
+			rejected = true
+		}
+	}
+
+	check(err)
+	if rejected {
+		os.Exit(ExitError)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/urfave/cli"
+	"github.com/vroy/git-gb/gb"
+)
+
+func renameBranches(ctx *cli.Context) error {
+	return renameOrCopy(ctx, false)
+}
+
+func copyBranches(ctx *cli.Context) error {
+	return renameOrCopy(ctx, true)
+}
+
+func renameOrCopy(ctx *cli.Context, copy bool) error {
+	repo := NewRepo()
+
+	verb := "rename"
+	if copy {
+		verb = "copy"
+	}
+
+	if len(ctx.Args()) != 2 {
+		exit("Usage: git gb %s <old> <new>, or git gb %s --pattern <regexp> <replacement>", verb, verb)
+	}
+
+	renames := []gb.Rename{{Old: ctx.Args().Get(0), New: ctx.Args().Get(1)}}
+
+	if ctx.Bool("pattern") {
+		pattern, err := regexp.Compile(ctx.Args().Get(0))
+		if err != nil {
+			exit("Invalid pattern: %s", err)
+		}
+
+		renames, err = gb.PlanRenames(repo, pattern, ctx.Args().Get(1))
+		check(err)
+
+		if len(renames) == 0 {
+			fmt.Println("No branch matches the pattern.")
+			return nil
+		}
+
+		if !ctx.Bool("apply") {
+			for _, rename := range renames {
+				fmt.Printf("%swould %s %s -> %s\n", Yellow, verb, rename.Old, rename.New)
+			}
+			fmt.Printf("%sRun again with --apply to %s them.\n", Reset, verb)
+			return nil
+		}
+	}
+
+	failed := false
+
+	for _, rename := range renames {
+		branch, err := gb.RenameBranch(repo, rename.Old, rename.New, copy)
+		if err != nil {
+			fmt.Printf("%sfailed to %s %s: %s\n", Red, verb, rename.Old, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s%s %s -> %s\n", Green, verb, rename.Old, rename.New)
+
+		if !ctx.Bool("push") {
+			continue
+		}
+
+		results, err := gb.PushRename(repo, branch, !copy, os.Stderr)
+		for _, result := range results {
+			if result.Status == "" {
+				fmt.Printf("%s  pushed %s\n", Green, result.Ref)
+			} else {
+				fmt.Printf("%s  rejected %s: %s\n", Red, result.Ref, result.Status)
+				failed = true
+			}
+		}
+		if err != nil {
+			fmt.Printf("%s  %s\n", Red, err)
+			failed = true
+		}
+	}
+
+	fmt.Print(Reset)
+	if failed {
+		os.Exit(ExitError)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/urfave/cli"
+	"github.com/vroy/git-gb/gb"
+)
+
+// writeReport writes the report to path, or to stdout when path is "-".
+func writeReport(path string, write func(io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func report(ctx *cli.Context) error {
+	htmlPath := ctx.String("html")
+	markdownPath := ctx.String("markdown")
+	if htmlPath == "" && markdownPath == "" {
+		exit("Nothing to write: use --html <file> or --markdown <file>.")
+	}
+
+	repo := NewRepo()
+
+	opts := gb.DefaultOptions()
+	opts.Base = gb.BaseBranch(repo, ctx.Args().First())
+
+	statuses, err := gb.List(context.Background(), repo, opts)
+	var partial *gb.PartialError
+	if errors.As(err, &partial) {
+		summarize(partial)
+	} else {
+		check(err)
+	}
+
+	r, err := gb.NewReport(repo, opts.Base, statuses)
+	check(err)
+
+	if htmlPath != "" {
+		check(writeReport(htmlPath, r.WriteHTML))
+		if htmlPath != "-" {
+			fmt.Fprintf(os.Stderr, "Wrote %s\n", htmlPath)
+		}
+	}
+
+	if markdownPath != "" {
+		check(writeReport(markdownPath, r.WriteMarkdown))
+		if markdownPath != "-" {
+			fmt.Fprintf(os.Stderr, "Wrote %s\n", markdownPath)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	git "github.com/libgit2/git2go/v34"
+	"github.com/urfave/cli"
+	"github.com/vroy/git-gb/gb"
+)
+
+const (
+	RestackStateFile = "gb_restack.json"
+	RestackUndoFile  = "gb_restack_undo.json"
+)
+
+// RefUpdate records a ref that was moved so it can be rolled back.
+type RefUpdate struct {
+	Ref    string
+	OldOid string
+	NewOid string
+}
+
+type RestackState struct {
+	Head    string
+	Pending []gb.RestackStep
+	Journal []RefUpdate
+}
+
+func restackPath(repo *git.Repository, name string) string {
+	return filepath.Join(repo.Path(), name)
+}
+
+func readJSON(path string, v interface{}) bool {
+	bits, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	if err := json.Unmarshal(bits, v); err != nil {
+		exit("Could not read '%s': %s", path, err)
+	}
+	return true
+}
+
+func writeJSON(path string, v interface{}) {
+	bits, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		exit("Could not encode '%s': %s", path, err)
+	}
+
+	if err := ioutil.WriteFile(path, bits, 0644); err != nil {
+		exit("Could not write '%s': %s", path, err)
+	}
+}
+
+func (state *RestackState) Save(repo *git.Repository) {
+	writeJSON(restackPath(repo, RestackStateFile), state)
+}
+
+// currentHead returns the ref HEAD points to, or the commit id when HEAD is
+// detached.
+func currentHead(repo *git.Repository) string {
+	detached, err := repo.IsHeadDetached()
+	if err != nil {
+		exit("Could not read HEAD: %s", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		exit("Could not read HEAD: %s", err)
+	}
+
+	if detached {
+		return head.Target().String()
+	}
+	return head.Name()
+}
+
+// checkoutHead checks out the tree of the given commit and points HEAD at
+// head, a ref name or a commit id saved by currentHead.
+func checkoutHead(repo *git.Repository, head string, oid *git.Oid) error {
+	commit, err := repo.LookupCommit(oid)
+	if err != nil {
+		return err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+
+	err = repo.CheckoutTree(tree, &git.CheckoutOptions{Strategy: git.CheckoutSafe})
+	if err != nil {
+		return err
+	}
+
+	if detached, err := git.NewOid(head); err == nil {
+		return repo.SetHeadDetached(detached)
+	}
+	return repo.SetHead(head)
+}
+
+func headTarget(repo *git.Repository, head string) *git.Oid {
+	if oid, err := git.NewOid(head); err == nil {
+		return oid
+	}
+
+	ref, err := repo.References.Lookup(head)
+	if err != nil {
+		exit("Could not lookup '%s': %s", head, err)
+	}
+	return ref.Target()
+}
+
+// RollBack moves every ref of the journal back to its old commit, newest
+// first, and restores HEAD. Refs that moved since are left alone.
+func RollBack(repo *git.Repository, head string, journal []RefUpdate) {
+	target := headTarget(repo, head)
+	for _, update := range journal {
+		if update.Ref == head {
+			target, _ = git.NewOid(update.OldOid)
+		}
+	}
+
+	if err := checkoutHead(repo, currentHead(repo), target); err != nil {
+		exit("Could not check out '%s': %s", head, err)
+	}
+
+	for i := len(journal) - 1; i >= 0; i-- {
+		update := journal[i]
+
+		ref, err := repo.References.Lookup(update.Ref)
+		if err != nil {
+			fmt.Printf("%sskipped %s: %s\n", Red, update.Ref, err)
+			continue
+		}
+
+		if ref.Target().String() != update.NewOid {
+			fmt.Printf("%sskipped %s: moved since it was restacked\n", Red, update.Ref)
+			continue
+		}
+
+		old, _ := git.NewOid(update.OldOid)
+		if _, err := ref.SetTarget(old, "gb restack: roll back"); err != nil {
+			exit("Could not reset '%s': %s", update.Ref, err)
+		}
+		fmt.Printf("%s%s reset to %s\n", Reset, update.Ref, update.OldOid[:7])
+	}
+
+	if err := checkoutHead(repo, head, target); err != nil {
+		exit("Could not check out '%s': %s", head, err)
+	}
+}
+
+func commitRebaseOperation(repo *git.Repository, rebase *git.Rebase, op *git.RebaseOperation) error {
+	original, err := repo.LookupCommit(op.Id)
+	if err != nil {
+		return err
+	}
+
+	committer, err := repo.DefaultSignature()
+	if err != nil {
+		return err
+	}
+
+	err = rebase.Commit(new(git.Oid), original.Author(), committer, original.Message())
+	if git.IsErrorCode(err, git.ErrorCodeApplied) {
+		// The change is already in the new parent: drop the commit.
+		return nil
+	}
+	return err
+}
+
+func hasConflicts(repo *git.Repository) bool {
+	index, err := repo.Index()
+	if err != nil {
+		exit("Could not read index: %s", err)
+	}
+	defer index.Free()
+
+	return index.HasConflicts()
+}
+
+func stopOnConflict(state *RestackState) {
+	step := state.Pending[0]
+	fmt.Printf("%sConflict while rebasing '%s' onto '%s'.\n", Red, step.Branch, step.Parent)
+	fmt.Printf("%sResolve the conflicts, stage them with `git add`, then run `git gb restack --continue`.\n", Reset)
+	fmt.Printf("To roll back the whole stack, run `git gb restack --abort`.\n")
+	os.Exit(1)
+}
+
+// startRestackStep begins the rebase of the first pending step, or returns nil
+// when the branch already sits on top of its parent.
+func startRestackStep(repo *git.Repository, step gb.RestackStep) *git.Rebase {
+	branch, err := repo.LookupBranch(step.Branch, git.BranchLocal)
+	if err != nil {
+		exit("Error looking up branch '%s'", step.Branch)
+	}
+
+	parent, err := repo.LookupBranch(step.Parent, git.BranchLocal)
+	if err != nil {
+		exit("Error looking up branch '%s'", step.Parent)
+	}
+
+	if branch.Target().Equal(parent.Target()) {
+		return nil
+	}
+
+	onParent, err := repo.DescendantOf(branch.Target(), parent.Target())
+	if err != nil {
+		exit("Could not get descendant of '%s' and '%s'.", step.Branch, step.Parent)
+	}
+	if onParent {
+		return nil
+	}
+
+	branchCommit, err := repo.AnnotatedCommitFromRef(branch.Reference)
+	if err != nil {
+		exit("Could not lookup '%s': %s", step.Branch, err)
+	}
+
+	upstreamOid, err := git.NewOid(step.Upstream)
+	if err != nil {
+		exit("Invalid upstream '%s' for '%s'", step.Upstream, step.Branch)
+	}
+
+	upstream, err := repo.LookupAnnotatedCommit(upstreamOid)
+	if err != nil {
+		exit("Could not lookup '%s': %s", step.Upstream, err)
+	}
+
+	onto, err := repo.LookupAnnotatedCommit(parent.Target())
+	if err != nil {
+		exit("Could not lookup '%s': %s", step.Parent, err)
+	}
+
+	rebase, err := repo.InitRebase(branchCommit, upstream, onto, nil)
+	if err != nil {
+		exit("Could not rebase '%s' onto '%s': %s", step.Branch, step.Parent, err)
+	}
+	return rebase
+}
+
+// applyRestack works through the pending steps. The rebase is the one in
+// progress for the first step, if any.
+func applyRestack(repo *git.Repository, state *RestackState, rebase *git.Rebase) {
+	for len(state.Pending) > 0 {
+		step := state.Pending[0]
+
+		branch, err := repo.LookupBranch(step.Branch, git.BranchLocal)
+		if err != nil {
+			exit("Error looking up branch '%s'", step.Branch)
+		}
+		oldOid := branch.Target().String()
+
+		if rebase == nil {
+			rebase = startRestackStep(repo, step)
+		}
+
+		if rebase != nil {
+			for {
+				op, err := rebase.Next()
+				if git.IsErrorCode(err, git.ErrorCodeIterOver) {
+					break
+				}
+				if err != nil {
+					exit("Could not rebase '%s': %s", step.Branch, err)
+				}
+
+				if hasConflicts(repo) {
+					state.Save(repo)
+					stopOnConflict(state)
+				}
+
+				if err := commitRebaseOperation(repo, rebase, op); err != nil {
+					exit("Could not commit rebased '%s': %s", step.Branch, err)
+				}
+			}
+
+			if err := rebase.Finish(); err != nil {
+				exit("Could not finish rebase of '%s': %s", step.Branch, err)
+			}
+			rebase.Free()
+			rebase = nil
+
+			branch, err = repo.LookupBranch(step.Branch, git.BranchLocal)
+			if err != nil {
+				exit("Error looking up branch '%s'", step.Branch)
+			}
+
+			if branch.Target().String() != oldOid {
+				state.Journal = append(state.Journal, RefUpdate{
+					Ref:    branch.Reference.Name(),
+					OldOid: oldOid,
+					NewOid: branch.Target().String(),
+				})
+			}
+			fmt.Printf("%s%s rebased onto %s\n", Green, step.Branch, step.Parent)
+		} else {
+			fmt.Printf("%s%s already on %s\n", Reset, step.Branch, step.Parent)
+		}
+
+		parent, err := repo.LookupBranch(step.Parent, git.BranchLocal)
+		if err != nil {
+			exit("Error looking up branch '%s'", step.Parent)
+		}
+		if config, err := repo.Config(); err == nil {
+			config.SetString(gb.StackParentKey(step.Branch), step.Parent)
+			config.SetString(gb.StackParentOidKey(step.Branch), parent.Target().String())
+		}
+
+		state.Pending = state.Pending[1:]
+		state.Save(repo)
+	}
+
+	if err := checkoutHead(repo, state.Head, headTarget(repo, state.Head)); err != nil {
+		exit("Could not check out '%s': %s", state.Head, err)
+	}
+
+	if len(state.Journal) > 0 {
+		writeJSON(restackPath(repo, RestackUndoFile), state.Journal)
+	}
+	os.Remove(restackPath(repo, RestackStateFile))
+}
+
+func restack(ctx *cli.Context) error {
+	repo := NewRepo()
+
+	state := new(RestackState)
+	inProgress := readJSON(restackPath(repo, RestackStateFile), state)
+
+	switch {
+	case ctx.Bool("continue"):
+		if !inProgress {
+			exit("No restack in progress.")
+		}
+
+		rebase, err := repo.OpenRebase(nil)
+		if err != nil {
+			exit("Could not open the rebase in progress: %s", err)
+		}
+
+		if hasConflicts(repo) {
+			stopOnConflict(state)
+		}
+
+		if index, err := rebase.CurrentOperationIndex(); err == nil {
+			if err := commitRebaseOperation(repo, rebase, rebase.OperationAt(index)); err != nil {
+				exit("Could not commit rebased '%s': %s", state.Pending[0].Branch, err)
+			}
+		}
+
+		applyRestack(repo, state, rebase)
+		return nil
+
+	case ctx.Bool("abort"):
+		if !inProgress {
+			exit("No restack in progress.")
+		}
+
+		if rebase, err := repo.OpenRebase(nil); err == nil {
+			if err := rebase.Abort(); err != nil {
+				exit("Could not abort the rebase in progress: %s", err)
+			}
+		}
+
+		RollBack(repo, state.Head, state.Journal)
+		os.Remove(restackPath(repo, RestackStateFile))
+		return nil
+
+	case ctx.Bool("undo"):
+		if inProgress {
+			exit("A restack is in progress; use --continue or --abort.")
+		}
+
+		journal := []RefUpdate{}
+		if !readJSON(restackPath(repo, RestackUndoFile), &journal) {
+			exit("Nothing to undo.")
+		}
+
+		RollBack(repo, currentHead(repo), journal)
+		os.Remove(restackPath(repo, RestackUndoFile))
+		return nil
+	}
+
+	if inProgress {
+		exit("A restack is already in progress; use --continue or --abort.")
+	}
+
+	opts := gb.DefaultOptions()
+	opts.RecordStacks = true
+	comparisons, _ := compare(repo, nil, opts)
+
+	branchName := ctx.Args().First()
+	if branchName != "" {
+		if _, err := repo.LookupBranch(branchName, git.BranchLocal); err != nil {
+			exit("Error looking up branch '%s'", branchName)
+		}
+	}
+
+	state.Head = currentHead(repo)
+	state.Pending = gb.PlanRestack(comparisons, branchName)
+	if len(state.Pending) == 0 {
+		fmt.Println("No stacked branches to restack.")
+		return nil
+	}
+
+	state.Save(repo)
+	applyRestack(repo, state, nil)
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/urfave/cli"
+	"github.com/vroy/git-gb/gb"
+)
+
+func serve(ctx *cli.Context) error {
+	repo := NewRepo()
+
+	addr := ctx.String("addr")
+	fmt.Fprintf(os.Stderr, "Serving %s on http://%s/\n", repo.Workdir(), addr)
+
+	check(http.ListenAndServe(addr, gb.NewServer(repo)))
+	return nil
+}
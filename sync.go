@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli"
+	"github.com/vroy/git-gb/gb"
+)
+
+func syncColorCode(r gb.SyncResult) string {
+	switch r.Status {
+	case gb.SyncUpdated:
+		return Green
+	case gb.SyncConflicts, gb.SyncSkipped:
+		return Red
+	default:
+		return Reset
+	}
+}
+
+func syncBranches(ctx *cli.Context) error {
+	strategy := ctx.String("strategy")
+	if strategy != "rebase" && strategy != "merge" {
+		exit("Unknown strategy '%s': use rebase or merge", strategy)
+	}
+
+	repo := NewRepo()
+
+	opts := gb.DefaultOptions()
+	opts.Flat = true
+	comparisons, baseBranch := compare(repo, ctx.Args(), opts)
+
+	pattern := compilePattern(ctx.String("pattern"))
+	checkedOut := gb.CheckedOut(repo)
+
+	branch_length := comparisons.MaxBranchLength()
+
+	for _, comp := range comparisons {
+		if comp.Err != nil || comp.IsBase(baseBranch) || comp.IsMerged {
+			continue
+		}
+
+		if pattern != nil && !comp.Matches(pattern) {
+			continue
+		}
+
+		result := gb.Sync(comp, baseBranch, strategy, checkedOut)
+
+		fmt.Printf(
+			"%s%-10s | %-*s | %s\n",
+			syncColorCode(result),
+			result.Status,
+			branch_length,
+			comp.Name(),
+			result.Detail)
+	}
+
+	return nil
+}
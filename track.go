@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli"
+	"github.com/vroy/git-gb/gb"
+)
+
+func untracked(ctx *cli.Context) error {
+	repo := NewRepo()
+
+	opts := gb.DefaultOptions()
+	opts.Base = gb.BaseBranch(repo, ctx.Args().First())
+	opts.Fetch = ctx.Generic("fetch").(*fetchFlag).value
+	opts.Progress = os.Stderr
+
+	comparisons, err := gb.CompareUntracked(context.Background(), repo, ctx.String("remote"), opts)
+	var partial *gb.PartialError
+	if errors.As(err, &partial) {
+		summarize(partial)
+	} else {
+		check(err)
+	}
+
+	if len(comparisons) == 0 {
+		fmt.Println("Every remote branch is tracked by a local branch.")
+		return nil
+	}
+
+	width := comparisons.MaxBranchLength()
+	for _, comp := range comparisons {
+		if comp.Err != nil {
+			continue
+		}
+
+		status := comp.Status(opts.Base)
+		fmt.Printf("%s%s | %-*s | behind: %4d | ahead: %4d\n",
+			ColorCode(status), FormattedWhen(status), width, status.Name, status.Behind, status.Ahead)
+	}
+
+	return nil
+}
+
+func track(ctx *cli.Context) error {
+	repo := NewRepo()
+
+	if len(ctx.Args()) == 0 {
+		exit("Usage: git gb track <remote-branch> [name]")
+	}
+
+	remoteBranch := ctx.Args().First()
+	branch, err := gb.Track(repo, remoteBranch, ctx.Args().Get(1))
+	check(err)
+
+	name, err := branch.Name()
+	check(err)
+
+	fmt.Printf("Branch '%s' set up to track '%s'.\n", name, remoteBranch)
+	return nil
+}